@@ -1,6 +1,12 @@
 package main
 
-import "os"
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/store"
+)
 
 type config struct {
 	PodIP        string
@@ -11,6 +17,14 @@ type config struct {
 	AdminKey     string
 	Host         string
 	Port         string
+	// port of the replication listener, bound to PodIP only
+	ReplicaPort string
+	// persistence of admin changes, see pkg/store
+	StateBackend    string
+	StateDir        string
+	StatePrecedence string
+	// how often configs/networks is checked for changes; 0 turns watching off
+	ReloadInterval time.Duration
 }
 
 func loadConfig() config {
@@ -23,6 +37,12 @@ func loadConfig() config {
 		AdminKey:     getEnv("ADMIN_API_KEY", "changeme"),
 		Host:         getEnv("SERVER_HOST", "0.0.0.0"),
 		Port:         getEnv("SERVER_PORT", "8080"),
+		ReplicaPort:  getEnv("REPLICA_PORT", "8081"),
+
+		StateBackend:    getEnv("STATE_BACKEND", "file"),
+		StateDir:        getEnv("STATE_DIR", "data"),
+		StatePrecedence: getEnv("STATE_PRECEDENCE", store.PrecedenceAdmin),
+		ReloadInterval:  getDuration("CONFIG_RELOAD_INTERVAL", 10*time.Second),
 	}
 }
 
@@ -32,3 +52,15 @@ func getEnv(key, def string) string {
 	}
 	return def
 }
+
+func getDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Fatalf("invalid %s=%q: want a duration like 10s, or 0 to disable", key, v)
+	}
+	return d
+}
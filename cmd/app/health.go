@@ -1,7 +1,6 @@
 package main
 
 import (
-	"github.com/shuliakovsky/rpc-forwarder/pkg/secrets"
 	"strings"
 	"sync"
 	"time"
@@ -35,7 +34,7 @@ func runInitialHealth(reg *registry.Registry, checker *health.Checker, logger *z
 			lim.acquire()
 			defer lim.release()
 
-			best := checker.UpdateNetwork(st.Protocol, st.All)
+			best := checker.UpdateNetwork(name, st.Protocol, st.All)
 			reg.SetBest(name, best)
 
 			metrics.TotalNodes.WithLabelValues(name).Set(float64(len(st.All) + len(st.Discovered)))
@@ -72,7 +71,7 @@ func startHealthLoop(reg *registry.Registry, checker *health.Checker, logger *za
 					lim.acquire()
 					defer lim.release()
 
-					best := checker.UpdateNetwork(st.Protocol, st.All)
+					best := checker.UpdateNetwork(name, st.Protocol, st.All)
 					reg.SetBest(name, best)
 					metrics.TotalNodes.WithLabelValues(name).Set(float64(len(st.All) + len(st.Discovered)))
 					metrics.HealthyNodes.WithLabelValues(name).Set(float64(len(best)))
@@ -81,13 +80,6 @@ func startHealthLoop(reg *registry.Registry, checker *health.Checker, logger *za
 				}(name, st)
 			}
 			wg.Wait()
-
-			// drop nodes marked as fatal during checks
-			for _, url := range checker.DrainDropURLs() {
-				reg.RemoveNodeEverywhere(url)
-				logger.Warn("unhealthy_node_dropped", zap.String("url", secrets.RedactString(url)))
-
-			}
 		}
 	}()
 }
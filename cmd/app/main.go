@@ -11,12 +11,14 @@ func main() {
 	defer logger.Sync()
 
 	peerStore, nodeID, internalAddr := initBootstrap(cfg, logger)
-	reg := initRegistry(cfg, logger)
+	reg, st, reloader := initRegistry(cfg, logger)
 	checker := initHealthChecker(cfg, reg, logger)
+	reloader.Checker = checker
 
 	runInitialHealth(reg, checker, logger)
 	startHealthLoop(reg, checker, logger)
 
-	registerRoutes(reg, checker, peerStore, nodeID, internalAddr, cfg, logger)
-	startServer(cfg.Host, cfg.Port, logger)
+	routes := registerRoutes(reg, checker, st, reloader, peerStore, nodeID, internalAddr, cfg, logger)
+	startConfigWatch(cfg, reloader, logger)
+	startServer(cfg.Host, cfg.Port, routes, logger)
 }
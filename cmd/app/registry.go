@@ -3,16 +3,62 @@ package main
 import (
 	"go.uber.org/zap"
 
-	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
 	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/reload"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/store"
 )
 
-func initRegistry(cfg config, logger *zap.Logger) *registry.Registry {
-	cfgs, err := networks.LoadAll("configs/networks", logger)
+const networksDir = "configs/networks"
+
+func initRegistry(cfg config, logger *zap.Logger) (*registry.Registry, *store.Store, *reload.Reloader) {
+	st := openStore(cfg, logger)
+	reg := registry.New()
+	reloader := reload.New(networksDir, reg, st, cfg.StatePrecedence, logger)
+	cfgs, err := reloader.Load()
 	if err != nil {
 		logger.Fatal("networks_load_error", zap.Error(err))
 	}
-	reg := registry.New()
 	reg.InitFromConfigs(cfgs)
-	return reg
+	return reg, st, reloader
+}
+
+// startConfigWatch reloads configs/networks whenever its files change.
+func startConfigWatch(cfg config, reloader *reload.Reloader, logger *zap.Logger) {
+	if cfg.ReloadInterval == 0 {
+		logger.Info("networks_watch_disabled")
+		return
+	}
+	logger.Info("networks_watch_started", zap.String("dir", reloader.Dir), zap.Duration("interval", cfg.ReloadInterval))
+	go reloader.Watch(cfg.ReloadInterval)
+}
+
+// openStore opens the persisted admin state; a nil store means persistence is off.
+func openStore(cfg config, logger *zap.Logger) *store.Store {
+	switch cfg.StateBackend {
+	case "none":
+		logger.Info("state_persistence_disabled")
+		return nil
+	case "file":
+	default:
+		logger.Fatal("state_backend_unknown", zap.String("backend", cfg.StateBackend))
+	}
+	if err := store.ValidatePrecedence(cfg.StatePrecedence); err != nil {
+		logger.Fatal("state_config_error", zap.Error(err))
+	}
+	b, err := store.NewFile(cfg.StateDir)
+	if err != nil {
+		logger.Fatal("state_open_error", zap.String("dir", cfg.StateDir), zap.Error(err))
+	}
+	st, saved, err := store.Open(b)
+	if err != nil {
+		logger.Fatal("state_load_error", zap.String("dir", cfg.StateDir), zap.Error(err))
+	}
+	logger.Info("state_loaded",
+		zap.String("dir", cfg.StateDir),
+		zap.String("precedence", cfg.StatePrecedence),
+		zap.Int("networks", len(saved.Networks)),
+		zap.Int("deleted", len(saved.Deleted)),
+		zap.Uint64("seq", saved.Seq),
+	)
+	return st
 }
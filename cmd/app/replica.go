@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/api"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/peers"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/replica"
+)
+
+// startReplication serves /replica on PodIP:REPLICA_PORT, away from the public listener,
+// and starts the periodic exchange with peers. Replicated ops change networks without
+// the admin key, so replication stays off unless SHARED_SECRET is set to a real secret.
+func startReplication(cfg config, adminAPI *api.Admin, peerStore *peers.Store, nodeID string, logger *zap.Logger) *replica.Replicator {
+	if err := replica.CheckSecret(cfg.SharedSecret); err != nil {
+		logger.Warn("replica_disabled", zap.Error(err))
+		return nil
+	}
+	r := replica.New(nodeID, cfg.SharedSecret, peerStore, logger)
+	r.Apply = adminAPI.ApplyReplicated
+	r.Restore = adminAPI.ApplyRestored
+	if adminAPI.Reloader != nil {
+		r.Skip = adminAPI.Reloader.Overrides
+	}
+	r.Port = cfg.ReplicaPort
+	if adminAPI.Store != nil {
+		r.Seed(adminAPI.Store.State())
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/replica", r.Handler())
+	addr := fmt.Sprintf("%s:%s", cfg.PodIP, cfg.ReplicaPort)
+	go func() {
+		logger.Info("replica_listening", zap.String("addr", addr))
+		if err := http.ListenAndServe(addr, mux); err != nil && err != http.ErrServerClosed {
+			logger.Error("replica_server_down", zap.Error(err))
+		}
+	}()
+	go r.Start(30 * time.Second)
+	return r
+}
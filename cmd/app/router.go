@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+// router serves the fixed endpoints registered on mux and hands /{route}[/tail] to
+// the proxy. Routes are looked up in the registry on every request, so networks
+// added or removed at runtime are reachable, or gone, right away. Fixed endpoints win
+// over a network with the same route.
+type router struct {
+	mux   *http.ServeMux
+	reg   *registry.Registry
+	proxy http.Handler
+}
+
+func newRouter(mux *http.ServeMux, reg *registry.Registry, proxy http.Handler) *router {
+	return &router{mux: mux, reg: reg, proxy: proxy}
+}
+
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h, pattern := rt.mux.Handler(r); pattern != "" {
+		h.ServeHTTP(w, r)
+		return
+	}
+	route, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if _, ok := rt.reg.Resolve(route); ok && route != "" {
+		rt.proxy.ServeHTTP(w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
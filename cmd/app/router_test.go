@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+func TestRouter_ResolvesRoutes(t *testing.T) {
+	reg := registry.New()
+	// YAML networks are named after their file: klaytn.yaml declares route /klay
+	reg.InitFromConfigs(map[string]networks.NetworkConfig{"klaytn": {Route: "/klay", Protocol: "evm"}})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	var proxied []string
+	rt := newRouter(mux, reg, http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.Path)
+	}))
+
+	serve := func(path string) int {
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		return rec.Code
+	}
+	require.Equal(t, http.StatusOK, serve("/klay"))
+	require.Equal(t, http.StatusOK, serve("/klay/extra"))
+	require.Equal(t, http.StatusNotFound, serve("/klaytn"))
+	require.Equal(t, http.StatusOK, serve("/healthz"))
+	require.Equal(t, []string{"/klay", "/klay/extra"}, proxied)
+}
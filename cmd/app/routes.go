@@ -20,51 +20,62 @@ import (
 	"github.com/shuliakovsky/rpc-forwarder/pkg/metrics"
 	"github.com/shuliakovsky/rpc-forwarder/pkg/peers"
 	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/reload"
 	"github.com/shuliakovsky/rpc-forwarder/pkg/secrets"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/store"
 )
 
 func registerRoutes(
 	reg *registry.Registry,
 	checker *health.Checker,
+	st *store.Store,
+	reloader *reload.Reloader,
 	peerStore *peers.Store,
 	nodeID string,
 	internalAddr string,
 	cfg config,
 	logger *zap.Logger,
-) {
+) http.Handler {
+	mux := http.NewServeMux()
 	public := api.NewPublic(reg, logger)
 	proxy := api.NewProxy(reg, logger, cfg.TorSocks)
 	adminAPI := api.NewAdmin(reg, checker, cfg.AdminKey, logger)
+	adminAPI.Proxy = proxy
+	adminAPI.Store = st
+	adminAPI.Reloader = reloader
+	reloader.OnChange = proxy.Forget
+	adminAPI.Replica = startReplication(cfg, adminAPI, peerStore, nodeID, logger)
 	wsAPI := api.NewWS(reg, logger)
 
 	// Core control endpoints
-	http.Handle("/announce", bootstrap.NewHandler(peerStore, nodeID, internalAddr, cfg.SharedSecret, logger))
-	http.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+	mux.Handle("/announce", bootstrap.NewHandler(peerStore, nodeID, internalAddr, cfg.SharedSecret, logger))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
 		_, _ = w.Write([]byte("ok"))
 	})
-	http.HandleFunc("/gossip", gossip.Handler(peerStore, logger))
-	http.HandleFunc("/heartbeat", leader.Handler(logger))
+	mux.HandleFunc("/gossip", gossip.Handler(peerStore, logger))
+	mux.HandleFunc("/heartbeat", leader.Handler(logger))
 
 	// Swagger
-	http.Handle("/swagger/", httpSwagger.Handler(
+	mux.Handle("/swagger/", httpSwagger.Handler(
 		httpSwagger.URL("/swagger/swagger.json"),
 		httpSwagger.InstanceName("swagger"),
 	))
-	http.HandleFunc("/swagger/swagger.json", docs.JSONHandler)
+	mux.HandleFunc("/swagger/swagger.json", docs.JSONHandler)
 
 	// Gossip state exchange
-	http.HandleFunc("/gossip-state", gossip.StateHandler(reg, logger))
+	mux.HandleFunc("/gossip-state", gossip.StateHandler(reg, logger))
 	go gossip.Publisher(reg, peerStore, nodeID, logger)
 
 	// Public routes
-	http.HandleFunc("/networkfees", public.NetworkFees)
-	http.HandleFunc("/active-nodes", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/networkfees", public.NetworkFees)
+	mux.HandleFunc("/active-nodes", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			return
 		}
 		type liteNode struct {
 			URL      string `json:"url"`
 			Priority int    `json:"priority"`
+			Ping     int64  `json:"ping"`
 		}
 		out := make(map[string][]liteNode)
 		for name, st := range reg.All() {
@@ -80,6 +91,7 @@ func registerRoutes(
 				arr = append(arr, liteNode{
 					URL:      masked,
 					Priority: n.Priority,
+					Ping:     n.Ping,
 				})
 			}
 			out[name] = arr
@@ -89,20 +101,22 @@ func registerRoutes(
 	})
 
 	// Fee helpers
-	http.HandleFunc("/proxy/eth/fee", public.EthFee)
-	http.HandleFunc("/proxy/eth/maxPriorityFee", public.EthMaxPriorityFee)
-	http.HandleFunc("/proxy/btc/fees", public.BTCFees)
-	http.HandleFunc("/proxy/btc/balance/", public.BTCBalance)
+	mux.HandleFunc("/proxy/eth/fee", public.EthFee)
+	mux.HandleFunc("/proxy/eth/maxPriorityFee", public.EthMaxPriorityFee)
+	mux.HandleFunc("/proxy/btc/fees", public.BTCFees)
+	mux.HandleFunc("/proxy/btc/balance/", public.BTCBalance)
 
 	// NFT helpers
-	http.HandleFunc("/proxy/nft/get-all-nfts/", public.NFTGetAllNFTs)
-	http.HandleFunc("/proxy/nft/get-nft-metadata/", public.NFTGetNFTMetadata)
-	http.HandleFunc("/proxy/eth/estimateGas", public.EthEstimateGas)
+	mux.HandleFunc("/proxy/nft/get-all-nfts/", public.NFTGetAllNFTs)
+	mux.HandleFunc("/proxy/nft/get-nft-metadata/", public.NFTGetNFTMetadata)
+	mux.HandleFunc("/proxy/eth/estimateGas", public.EthEstimateGas)
 
 	// Admin routes
-	http.HandleFunc("/admin/networks", adminAPI.AddNetwork)
-	http.HandleFunc("/admin/networks/bulk", adminAPI.AddNetworksBulk)
-	http.HandleFunc("/admin/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/admin/networks", adminAPI.AddNetwork)
+	mux.HandleFunc("/admin/networks/bulk", adminAPI.AddNetworksBulk)
+	mux.HandleFunc("/admin/networks/", adminAPI.Networks)
+	mux.HandleFunc("/admin/reload", adminAPI.Reload)
+	mux.HandleFunc("/admin/", func(w http.ResponseWriter, r *http.Request) {
 		switch {
 		case strings.HasSuffix(r.URL.Path, "/nodes") && r.Method == http.MethodGet:
 			adminAPI.ListNodes(w, r)
@@ -116,19 +130,12 @@ func registerRoutes(
 	})
 
 	// WebSocket
-	http.HandleFunc("/ws/", wsAPI.ServeWS)
-
-	// Dynamic proxy routes from registry
-	for name, st := range reg.All() {
-		route := st.Route
-		logger.Info("route_registered", zap.String("network", name), zap.String("route", route))
-		http.HandleFunc(route, proxy.Serve)
-		if !strings.HasSuffix(route, "/") {
-			http.HandleFunc(route+"/", proxy.Serve)
-		}
-	}
+	mux.HandleFunc("/ws/", wsAPI.ServeWS)
 
 	// Metrics
 	metrics.Init()
-	http.Handle("/metrics", metrics.Handler())
+	mux.Handle("/metrics", metrics.Handler())
+
+	// Everything else is /{network}[/tail], resolved against the live registry
+	return newRouter(mux, reg, http.HandlerFunc(proxy.Serve))
 }
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
-	"strings"
 
 	"go.uber.org/zap"
 )
@@ -18,31 +17,17 @@ type Result struct {
 	AllowedHostSubstr []string          // If specified, the proxy forwards requests only to upstreams whose URLs contain at least one of the defined substrings.
 }
 
-func Adapt(network, protocol, baseURL, tail, method string, hdr http.Header, body []byte, logger *zap.Logger) Result {
-	switch strings.ToLower(network) {
-	case "trx":
-		return adaptTRX(tail, method, hdr, body, logger)
-	case "btc":
-		return adaptBTC(tail, method, hdr, body, logger)
-	case "nft":
-		return adaptNFT(tail, method, hdr, body, logger)
-	case "sol":
-		return adaptSOL(tail, method, hdr, body, logger)
-	case "doge":
-		return adaptDOGE(tail, method, hdr, body, logger, baseURL)
-	case "ltc":
-		return adaptLTC(tail, method, hdr, body, logger, baseURL)
-	default:
-		// default behaviour
-		if strings.EqualFold(protocol, "evm") {
-			return adaptEVM(tail, method, hdr, body, logger)
-		}
-		return Result{
-			Tail:    tail,
-			Method:  method,
-			Body:    clone(body),
-			Headers: map[string]string{},
-		}
+// Func rewrites a client request for the upstreams of a chain family. baseURL is the
+// first candidate upstream; some adapters pick the request shape by provider.
+type Func func(baseURL, tail, method string, hdr http.Header, body []byte, logger *zap.Logger) Result
+
+// Passthrough forwards the request as is.
+func Passthrough(_, tail, method string, _ http.Header, body []byte, _ *zap.Logger) Result {
+	return Result{
+		Tail:    tail,
+		Method:  method,
+		Body:    clone(body),
+		Headers: map[string]string{},
 	}
 }
 
@@ -10,7 +10,7 @@ import (
 // Conservative adapter: pass explicit REST paths as is,
 // add two safe scenarios: /fees and /balance/{address} (→ Tatum),
 // otherwise — fallback to default behavior.
-func adaptBTC(tail, method string, _ http.Header, body []byte, logger *zap.Logger) Result {
+func BTC(_, tail, method string, _ http.Header, body []byte, logger *zap.Logger) Result {
 	ltail := strings.ToLower(strings.TrimPrefix(tail, "/"))
 
 	// Пустой хвост → Blockstream, но с fallback на Tatum
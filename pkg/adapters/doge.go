@@ -7,7 +7,8 @@ import (
 	"go.uber.org/zap"
 )
 
-func adaptDOGE(tail, method string, _ http.Header, body []byte, logger *zap.Logger, baseURL string) Result {
+// DOGE maps REST routes to the shape of the provider behind baseURL.
+func DOGE(baseURL, tail, method string, _ http.Header, body []byte, logger *zap.Logger) Result {
 	ltail := strings.ToLower(strings.TrimPrefix(tail, "/"))
 	lbase := strings.ToLower(baseURL)
 
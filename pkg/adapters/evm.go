@@ -7,7 +7,8 @@ import (
 	"go.uber.org/zap"
 )
 
-func adaptEVM(tail, method string, _ http.Header, body []byte, logger *zap.Logger) Result {
+// EVM passes JSON-RPC through and maps a few convenience GET routes to JSON-RPC calls.
+func EVM(_, tail, method string, _ http.Header, body []byte, logger *zap.Logger) Result {
 	ltail := strings.ToLower(strings.TrimPrefix(tail, "/"))
 
 	// Поддержка удобных GET-маршрутов
@@ -6,7 +6,8 @@ import (
 	"strings"
 )
 
-func adaptLTC(tail, method string, _ http.Header, body []byte, logger *zap.Logger, baseURL string) Result {
+// LTC maps REST routes to the shape of the provider behind baseURL.
+func LTC(baseURL, tail, method string, _ http.Header, body []byte, logger *zap.Logger) Result {
 	ltail := strings.ToLower(strings.TrimPrefix(tail, "/"))
 	lbase := strings.ToLower(baseURL)
 
@@ -15,7 +15,7 @@ import (
 // - If full JSON-RPC is already provided — do not interfere
 //
 // Requirement: "nft" config must point to EVM RPC
-func adaptNFT(tail, method string, _ http.Header, body []byte, logger *zap.Logger) Result {
+func NFT(_, tail, method string, _ http.Header, body []byte, logger *zap.Logger) Result {
 	// in case of request is  JSON-RPC — as is
 	if m := readJSON(body); m != nil && strings.EqualFold(asString(m["method"]), "eth_call") {
 		return Result{
@@ -8,7 +8,8 @@ import (
 	"go.uber.org/zap"
 )
 
-func adaptSOL(tail, method string, _ http.Header, body []byte, logger *zap.Logger) Result {
+// SOL adapts requests for Solana JSON-RPC upstreams.
+func SOL(_, tail, method string, _ http.Header, body []byte, logger *zap.Logger) Result {
 	ltail := strings.ToLower(strings.TrimPrefix(tail, "/"))
 
 	// Уважение GET: только известные шорткаты преобразуем, остальное — пробрасываем как есть
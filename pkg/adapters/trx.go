@@ -11,7 +11,7 @@ import (
 // - /wallet/* and /v1/* are passed as is;
 // - /balance/{address} → TronGrid /v1/accounts/{address} (GET);
 // - otherwise: default behaviour — wallet/getnowblock.
-func adaptTRX(tail, method string, _ http.Header, body []byte, logger *zap.Logger) Result {
+func TRX(_, tail, method string, _ http.Header, body []byte, logger *zap.Logger) Result {
 	ltail := strings.ToLower(strings.TrimPrefix(tail, "/"))
 
 	// 1) extension: balance TRX via TronGrid
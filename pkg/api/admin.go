@@ -4,11 +4,16 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/shuliakovsky/rpc-forwarder/pkg/health"
 	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
 	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/reload"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/replica"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/store"
 	"go.uber.org/zap"
 )
 
@@ -17,6 +22,14 @@ type Admin struct {
 	Checker  *health.Checker
 	AdminKey string
 	Logger   *zap.Logger
+	// Proxy, if set, forgets cached replies of deleted and reconfigured networks
+	Proxy *Proxy
+	// Store, if set, persists every change so it survives restarts
+	Store *store.Store
+	// Reloader, if set, re-reads configs/networks on POST /admin/reload
+	Reloader *reload.Reloader
+	// Replica, if set, replicates every change to the other replicas
+	Replica *replica.Replicator
 }
 
 func NewAdmin(reg *registry.Registry, checker *health.Checker, key string, logger *zap.Logger) *Admin {
@@ -51,15 +64,21 @@ func (a *Admin) AddNetwork(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "missing required fields", http.StatusBadRequest)
 		return
 	}
+	if err := nc.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	// обрезаем / из начала
 	nc.Route = strings.Trim(nc.Route, "/")
 
-	best := a.Checker.UpdateNetwork(nc.Protocol, nc.Nodes)
+	nc.Nodes = a.Checker.VerifyChain(nc.Route, nc.Protocol, nc.ChainIdentity(), nc.Nodes)
+	best := a.Checker.UpdateNetwork(nc.Route, nc.Protocol, nc.Nodes)
 	if len(best) == 0 {
 		http.Error(w, "no healthy nodes", http.StatusBadRequest)
 		return
 	}
 	a.Reg.AddNetwork(nc, best)
+	a.persist(nc.Route)
 	a.Logger.Info("admin_add_network", zap.String("route", nc.Route), zap.Int("healthy_nodes", len(best)))
 	resp := map[string]any{"status": "added", "healthyNodes": best}
 	writeJSON(w, http.StatusOK, resp)
@@ -87,13 +106,14 @@ func (a *Admin) AddNode(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "bad json", http.StatusBadRequest)
 		return
 	}
-	best := a.Checker.UpdateNetwork(a.Reg.ProtocolOf(network), []networks.Node{node})
+	best := a.Checker.UpdateNetwork(network, a.Reg.ProtocolOf(network), []networks.Node{node})
 	if len(best) == 0 {
 		http.Error(w, "node not healthy", http.StatusBadRequest)
 		return
 	}
 	a.Reg.AddNode(network, node)
 	a.Reg.AppendBest(network, best[0])
+	a.persist(network)
 	a.Logger.Info("admin_add_node", zap.String("network", network), zap.String("url", node.URL))
 	resp := map[string]any{"status": "added", "node": best[0]}
 	writeJSON(w, http.StatusOK, resp)
@@ -120,8 +140,9 @@ func (a *Admin) ListNodes(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "unknown network", http.StatusNotFound)
 		return
 	}
-	writeJSON(w, http.StatusOK, st.All)
-	respBytes, _ := json.Marshal(st.All)
+	nodes := a.poolView(network, st)
+	writeJSON(w, http.StatusOK, nodes)
+	respBytes, _ := json.Marshal(nodes)
 	LogResponse(a.Logger, "admin_list_nodes", http.StatusOK, respBytes, start)
 }
 
@@ -169,6 +190,7 @@ func (a *Admin) DeleteNode(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	a.Reg.State[network].All = newAll
+	a.persist(network)
 
 	a.Logger.Info("admin_delete_node", zap.String("network", network), zap.String("url", payload.URL))
 	resp := map[string]any{"status": "removed", "url": payload.URL}
@@ -211,6 +233,15 @@ func (a *Admin) AddNetworksBulk(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
+		if err := nc.Validate(); err != nil {
+			result = append(result, map[string]any{
+				"route":  route,
+				"status": "skipped",
+				"reason": err.Error(),
+			})
+			continue
+		}
+
 		// дубликат
 		if a.Reg.Exists(route) {
 			result = append(result, map[string]any{
@@ -222,7 +253,8 @@ func (a *Admin) AddNetworksBulk(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// health‑check
-		best := a.Checker.UpdateNetwork(nc.Protocol, nc.Nodes)
+		nc.Nodes = a.Checker.VerifyChain(route, nc.Protocol, nc.ChainIdentity(), nc.Nodes)
+		best := a.Checker.UpdateNetwork(route, nc.Protocol, nc.Nodes)
 		if len(best) == 0 {
 			result = append(result, map[string]any{
 				"route":  route,
@@ -234,6 +266,7 @@ func (a *Admin) AddNetworksBulk(w http.ResponseWriter, r *http.Request) {
 
 		nc.Route = route
 		a.Reg.AddNetwork(nc, best)
+		a.persist(route)
 		a.Logger.Info("admin_bulk_add_network", zap.String("route", route), zap.Int("healthy_nodes", len(best)))
 		result = append(result, map[string]any{
 			"route":        route,
@@ -247,6 +280,353 @@ func (a *Admin) AddNetworksBulk(w http.ResponseWriter, r *http.Request) {
 	LogResponse(a.Logger, "admin_add_networks_bulk", http.StatusOK, respBytes, start)
 }
 
+// Network lifecycle:
+//
+//	DELETE /admin/networks/{name}
+//	PATCH  /admin/networks/{name}          any NetworkConfig fields
+//	POST   /admin/networks/{name}/enable
+//	POST   /admin/networks/{name}/disable
+//	DELETE /admin/networks/{name}/state
+func (a *Admin) Networks(w http.ResponseWriter, r *http.Request) {
+	name, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/networks/"), "/"), "/")
+	switch {
+	case name == "":
+		http.NotFound(w, r)
+	case action == "" && r.Method == http.MethodDelete:
+		a.DeleteNetwork(w, r, name)
+	case action == "" && r.Method == http.MethodPatch:
+		a.UpdateNetwork(w, r, name)
+	case (action == "enable" || action == "disable") && r.Method == http.MethodPost:
+		a.SetNetworkEnabled(w, r, name, action == "enable")
+	case action == "state" && r.Method == http.MethodDelete:
+		a.ResetNetworkState(w, r, name)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// DELETE /admin/networks/{name}
+func (a *Admin) DeleteNetwork(w http.ResponseWriter, r *http.Request, name string) {
+	start := LogRequest(a.Logger, "admin_delete_network", r.Method, r.URL.Path, nil)
+
+	if !a.auth(w, r) {
+		return
+	}
+	if !a.Reg.RemoveNetwork(name) {
+		http.Error(w, "unknown network", http.StatusNotFound)
+		return
+	}
+	a.forget(name)
+	a.persistDelete(name)
+	a.Logger.Info("admin_delete_network", zap.String("network", name))
+	resp := map[string]any{"status": "removed", "network": name}
+	writeJSON(w, http.StatusOK, resp)
+	respBytes, _ := json.Marshal(resp)
+	LogResponse(a.Logger, "admin_delete_network", http.StatusOK, respBytes, start)
+}
+
+// PATCH /admin/networks/{name}
+//
+// The body holds the fields to change; nested objects (cache, hedge, ...) are merged,
+// lists (nodes, routing, ...) replaced. A new route renames the network. The network
+// is health-checked right away but is updated even if no node is healthy.
+func (a *Admin) UpdateNetwork(w http.ResponseWriter, r *http.Request, name string) {
+	bodyBytes, _ := io.ReadAll(r.Body)
+	_ = r.Body.Close()
+	start := LogRequest(a.Logger, "admin_update_network", r.Method, r.URL.Path, bodyBytes)
+
+	if !a.auth(w, r) {
+		return
+	}
+	nc, ok := a.Reg.Config(name)
+	if !ok {
+		http.Error(w, "unknown network", http.StatusNotFound)
+		return
+	}
+	if err := clearPatchedLists(&nc, bodyBytes); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if err := json.Unmarshal(bodyBytes, &nc); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	nc.Route = strings.Trim(nc.Route, "/")
+	if nc.Route == "" || nc.Protocol == "" || len(nc.Nodes) == 0 {
+		http.Error(w, "missing required fields", http.StatusBadRequest)
+		return
+	}
+	if err := nc.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := a.Reg.ReplaceNetwork(name, nc, nil); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	a.forget(name)
+	if nc.Route != name {
+		a.persistDelete(name)
+	}
+	a.persist(nc.Route)
+	best := a.Checker.UpdateNetwork(nc.Route, nc.Protocol, nc.Nodes)
+	a.Reg.SetBest(nc.Route, best)
+
+	a.Logger.Info("admin_update_network",
+		zap.String("network", name),
+		zap.String("route", nc.Route),
+		zap.Int("healthy_nodes", len(best)),
+	)
+	resp := map[string]any{"status": "updated", "network": nc.Route, "healthyNodes": len(best)}
+	writeJSON(w, http.StatusOK, resp)
+	respBytes, _ := json.Marshal(resp)
+	LogResponse(a.Logger, "admin_update_network", http.StatusOK, respBytes, start)
+}
+
+// clearPatchedLists empties the lists a PATCH body sets, so decoding the patch replaces
+// them: encoding/json decodes into existing slice elements, which would otherwise keep
+// fields of the old entries, such as the headers of the node at the same index.
+func clearPatchedLists(nc *networks.NetworkConfig, patch []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &fields); err != nil {
+		return err
+	}
+	if _, ok := fields["nodes"]; ok {
+		nc.Nodes = nil
+	}
+	if _, ok := fields["routing"]; ok {
+		nc.Routing = nil
+	}
+	var nested map[string]json.RawMessage
+	if raw, ok := fields["methods"]; ok && json.Unmarshal(raw, &nested) == nil {
+		if _, ok := nested["allow"]; ok {
+			nc.Methods.Allow = nil
+		}
+		if _, ok := nested["deny"]; ok {
+			nc.Methods.Deny = nil
+		}
+	}
+	nested = nil
+	if raw, ok := fields["quorum"]; ok && json.Unmarshal(raw, &nested) == nil {
+		if _, ok := nested["methods"]; ok {
+			nc.Quorum.Methods = nil
+		}
+	}
+	return nil
+}
+
+// POST /admin/networks/{name}/enable, POST /admin/networks/{name}/disable
+func (a *Admin) SetNetworkEnabled(w http.ResponseWriter, r *http.Request, name string, enabled bool) {
+	start := LogRequest(a.Logger, "admin_set_network_enabled", r.Method, r.URL.Path, nil)
+
+	if !a.auth(w, r) {
+		return
+	}
+	if !a.Reg.SetDisabled(name, !enabled) {
+		http.Error(w, "unknown network", http.StatusNotFound)
+		return
+	}
+	a.persist(name)
+	status := "enabled"
+	if !enabled {
+		status = "disabled"
+	}
+	a.Logger.Info("admin_set_network_enabled", zap.String("network", name), zap.Bool("enabled", enabled))
+	resp := map[string]any{"status": status, "network": name}
+	writeJSON(w, http.StatusOK, resp)
+	respBytes, _ := json.Marshal(resp)
+	LogResponse(a.Logger, "admin_set_network_enabled", http.StatusOK, respBytes, start)
+}
+
+// DELETE /admin/networks/{name}/state
+//
+// Drops the persisted admin changes of a network and puts it back as configs/networks
+// defines it, so YAML edits apply to it again. A network that only exists through the
+// admin API is removed.
+func (a *Admin) ResetNetworkState(w http.ResponseWriter, r *http.Request, name string) {
+	start := LogRequest(a.Logger, "admin_reset_network_state", r.Method, r.URL.Path, nil)
+
+	if !a.auth(w, r) {
+		return
+	}
+	if a.Reloader == nil {
+		http.Error(w, "reload not configured", http.StatusNotImplemented)
+		return
+	}
+	if !a.Reloader.Known(name) {
+		http.Error(w, "unknown network", http.StatusNotFound)
+		return
+	}
+	v := a.Replica.PublishRestore(name)
+	if _, err := a.Reloader.Restore(name, v); err != nil {
+		a.Logger.Error("admin_persist_failed", zap.String("network", name), zap.Error(err))
+		http.Error(w, "state write failed", http.StatusInternalServerError)
+		return
+	}
+	status := "restored"
+	if !a.Reg.Has(name) {
+		status = "removed"
+	}
+	a.Logger.Info("admin_reset_network_state", zap.String("network", name), zap.String("status", status))
+	resp := map[string]any{"status": status, "network": name}
+	writeJSON(w, http.StatusOK, resp)
+	respBytes, _ := json.Marshal(resp)
+	LogResponse(a.Logger, "admin_reset_network_state", http.StatusOK, respBytes, start)
+}
+
+// Reload re-reads configs/networks and reports which networks were added, updated and
+// removed. An invalid config is rejected and the running config stays active.
+func (a *Admin) Reload(w http.ResponseWriter, r *http.Request) {
+	start := LogRequest(a.Logger, "admin_reload", r.Method, r.URL.Path, nil)
+
+	if !a.auth(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.Reloader == nil {
+		http.Error(w, "reload not configured", http.StatusNotImplemented)
+		return
+	}
+	diff, err := a.Reloader.Reload()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	writeJSON(w, http.StatusOK, diff)
+	respBytes, _ := json.Marshal(diff)
+	LogResponse(a.Logger, "admin_reload", http.StatusOK, respBytes, start)
+}
+
+// ApplyReplicated installs a change made through the admin API of another replica;
+// cfg is nil when the network was deleted. Health results of unchanged nodes are kept,
+// and a network new to this replica is health-checked in the background.
+func (a *Admin) ApplyReplicated(network string, cfg *networks.NetworkConfig, v store.Version) {
+	a.forget(network)
+	a.save(network, cfg, v)
+	if cfg == nil {
+		a.Reg.RemoveNetwork(network)
+		return
+	}
+	known := a.Reg.Has(network)
+	a.Reg.ApplyConfig(network, *cfg)
+	if !known && a.Checker != nil {
+		go func(nc networks.NetworkConfig) {
+			a.Reg.SetBest(network, a.Checker.UpdateNetwork(network, nc.Protocol, nc.Nodes))
+		}(*cfg)
+	}
+}
+
+// ApplyRestored drops the admin state of a network on a restore replicated from another
+// replica, putting the network back as the local configs/networks defines it.
+func (a *Admin) ApplyRestored(network string, v store.Version) {
+	if a.Reloader == nil {
+		return
+	}
+	if _, err := a.Reloader.Restore(network, v); err != nil {
+		a.Logger.Error("admin_persist_failed", zap.String("network", network), zap.Error(err))
+	}
+}
+
+// persist records the current config of a network and replicates it to the peers;
+// failures are logged, the change stays in effect until restart.
+func (a *Admin) persist(network string) {
+	nc, ok := a.Reg.Config(network)
+	if !ok {
+		return
+	}
+	a.save(network, &nc, a.Replica.Publish(network, &nc))
+}
+
+func (a *Admin) persistDelete(network string) {
+	a.save(network, nil, a.Replica.Publish(network, nil))
+}
+
+// save writes a network config, or its deletion when nc is nil, made at version v to
+// the Store.
+func (a *Admin) save(network string, nc *networks.NetworkConfig, v store.Version) {
+	var err error
+	if nc == nil {
+		err = a.Store.Delete(network, v)
+	} else {
+		err = a.Store.Put(network, *nc, v)
+	}
+	if err != nil {
+		a.Logger.Error("admin_persist_failed", zap.String("network", network), zap.Error(err))
+	}
+}
+
+func (a *Admin) forget(network string) {
+	if a.Proxy != nil {
+		a.Proxy.Forget(network)
+	}
+}
+
+// adminNode is a node as shown in admin listings, with its live proxy state.
+type adminNode struct {
+	registry.NodeWithPing
+	Circuit       string     `json:"circuit"`
+	CooldownUntil *time.Time `json:"cooldownUntil,omitempty"`
+	Score         float64    `json:"score"` // passive health score, 0..1
+	EjectedUntil  *time.Time `json:"ejectedUntil,omitempty"`
+	Quarantined   bool       `json:"quarantined,omitempty"`
+	RetryAt       *time.Time `json:"retryAt,omitempty"` // next probe of a quarantined node
+}
+
+// poolView lists every configured node of a network with its health, ordered
+// by priority tier and ping; nodes missing from Best are reported as not alive,
+// with the reason from the last health round.
+func (a *Admin) poolView(network string, st *registry.NetworkState) []adminNode {
+	best := make(map[string]registry.NodeWithPing, len(st.Best))
+	for _, n := range st.Best {
+		best[n.URL] = n
+	}
+	status := a.Reg.NodeStatus(network)
+	nodes := make([]registry.NodeWithPing, 0, len(st.All))
+	for _, n := range st.All {
+		if b, ok := best[n.URL]; ok {
+			nodes = append(nodes, b)
+			continue
+		}
+		// out of rotation: show the last health result and why
+		if s, ok := status[n.URL]; ok && !s.Alive {
+			s.Node = n
+			nodes = append(nodes, s)
+			continue
+		}
+		nodes = append(nodes, registry.NodeWithPing{Node: n})
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].Priority != nodes[j].Priority {
+			return nodes[i].Priority < nodes[j].Priority
+		}
+		if nodes[i].Alive != nodes[j].Alive {
+			return nodes[i].Alive
+		}
+		return nodes[i].Ping < nodes[j].Ping
+	})
+
+	out := make([]adminNode, 0, len(nodes))
+	for _, n := range registry.SanitizeNodes(nodes) {
+		stats := a.Reg.Stats(n.URL)
+		v := adminNode{NodeWithPing: n, Circuit: stats.Breaker().String(), Score: stats.Score()}
+		if until, ok := stats.CooldownUntil(); ok {
+			v.CooldownUntil = &until
+		}
+		if until, ok := stats.EjectedUntil(); ok {
+			v.EjectedUntil = &until
+		}
+		if retry, ok := stats.QuarantinedUntil(); ok {
+			v.Quarantined = true
+			v.RetryAt = &retry
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
 func writeJSON(w http.ResponseWriter, code int, v any) {
 	w.Header().Set("content-type", "application/json")
 	w.WriteHeader(code)
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/health"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+func TestAdmin_NetworkLifecycle(t *testing.T) {
+	up := namedRPC("up")
+	defer up.Close()
+
+	reg := registry.New()
+	reg.AddNetwork(networks.NetworkConfig{
+		Route:    "/eth",
+		Protocol: "evm",
+		Nodes:    []networks.Node{{URL: up.URL, Priority: 1}},
+	}, []registry.NodeWithPing{{Node: networks.Node{URL: up.URL, Priority: 1}, Alive: true}})
+	p := NewProxy(reg, zap.NewNop(), "")
+	a := NewAdmin(reg, health.New("", zap.NewNop(), reg), "key", zap.NewNop())
+	a.Proxy = p
+
+	admin := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("x-admin-key", "key")
+		a.Networks(rec, req)
+		return rec
+	}
+	proxy := func(network string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		p.Serve(rec, httptest.NewRequest(http.MethodPost, "/"+network,
+			strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`)))
+		return rec
+	}
+
+	require.Equal(t, http.StatusOK, admin(http.MethodPost, "/admin/networks/eth/disable", "").Code)
+	rec := proxy("eth")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Contains(t, rec.Body.String(), "network eth is disabled")
+	require.Equal(t, http.StatusOK, admin(http.MethodPost, "/admin/networks/eth/enable", "").Code)
+	require.Equal(t, http.StatusOK, proxy("eth").Code)
+
+	require.Equal(t, http.StatusBadRequest, admin(http.MethodPatch, "/admin/networks/eth", `{"strategy":"fastest"}`).Code)
+	require.Equal(t, http.StatusNotFound, admin(http.MethodPatch, "/admin/networks/btc", `{"timeoutMs":500}`).Code)
+
+	// rename and retune: the network moves, health is checked right away
+	rec = admin(http.MethodPatch, "/admin/networks/eth", `{"route":"/mainnet","timeoutMs":500,"cache":{"enabled":true}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.False(t, reg.Has("eth"))
+	cfg, ok := reg.Config("mainnet")
+	require.True(t, ok)
+	require.Equal(t, 500, cfg.TimeoutMs)
+	require.True(t, cfg.Cache.Enabled)
+	require.Equal(t, "evm", cfg.Protocol, "fields missing from the patch are kept")
+	require.Len(t, cfg.Nodes, 1)
+	require.Equal(t, http.StatusOK, proxy("mainnet").Code)
+
+	require.Equal(t, http.StatusOK, admin(http.MethodDelete, "/admin/networks/mainnet", "").Code)
+	require.False(t, reg.Has("mainnet"))
+	require.Equal(t, http.StatusNotFound, admin(http.MethodDelete, "/admin/networks/mainnet", "").Code)
+}
+
+func TestAdmin_UpdateNetworkReplacesLists(t *testing.T) {
+	reg := registry.New()
+	reg.AddNetwork(networks.NetworkConfig{
+		Route:    "/eth",
+		Protocol: "evm",
+		Nodes: []networks.Node{{
+			URL:      "https://old.example",
+			Priority: 2,
+			Tor:      true,
+			Headers:  map[string]string{"x-api-key": "secret"},
+		}},
+		Routing: []networks.MethodRoute{{Methods: []string{"debug_*"}, Tags: []string{"archive"}, MinBlockRange: 10}},
+		Methods: networks.MethodPolicy{Allow: []string{"eth_*"}, Deny: []string{"eth_sign"}},
+		Quorum:  networks.QuorumConfig{Size: 3, Methods: []string{"eth_call", "eth_getBalance"}},
+	}, nil)
+	a := NewAdmin(reg, health.New("", zap.NewNop(), reg), "key", zap.NewNop())
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPatch, "/admin/networks/eth", strings.NewReader(`{
+		"nodes": [{"url": "https://new.example"}],
+		"routing": [{"methods": ["trace_*"], "tags": ["trace"]}],
+		"methods": {"deny": ["eth_sendTransaction"]},
+		"quorum": {"methods": ["eth_getCode"]}
+	}`))
+	req.Header.Set("x-admin-key", "key")
+	a.Networks(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	cfg, ok := reg.Config("eth")
+	require.True(t, ok)
+	require.Equal(t, []networks.Node{{URL: "https://new.example"}}, cfg.Nodes, "nothing of the old node is kept")
+	require.Equal(t, []networks.MethodRoute{{Methods: []string{"trace_*"}, Tags: []string{"trace"}}}, cfg.Routing)
+	require.Equal(t, []string{"eth_*"}, cfg.Methods.Allow, "lists missing from the patch are kept")
+	require.Equal(t, []string{"eth_sendTransaction"}, cfg.Methods.Deny)
+	require.Equal(t, []string{"eth_getCode"}, cfg.Quorum.Methods)
+	require.Equal(t, 3, cfg.Quorum.Size)
+}
@@ -0,0 +1,339 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/adapters"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/metrics"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/protocols"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+// defaultMaxBatchSize is used when a network doesn't set maxBatchSize.
+// Many free endpoints reject batches above ~50 calls.
+const defaultMaxBatchSize = 20
+
+// batchItem is one call of a client batch. Calls are sent upstream with
+// their index as id, so replies can be matched regardless of client ids.
+type batchItem struct {
+	origID   json.RawMessage // nil for notifications
+	body     []byte          // adapted call with the internal id
+	reply    json.RawMessage // last reply seen for this call (internal id)
+	done     bool
+	bad      bool     // invalid request, never sent upstream
+	blocked  bool     // rejected by the method policy, never sent upstream
+	cacheKey string   // set when the reply may be cached
+	tags     []string // node tags required by routing rules
+	height   uint64   // highest block the call reads at, 0 if none
+	missed   []string // upstreams that answered with a retryable error
+	noRoute  bool     // no candidate carries the required tags
+}
+
+// isBatchRequest reports whether the request is a JSON-RPC batch on a route that supports fan-out.
+func isBatchRequest(method, protocol string, body []byte) bool {
+	if method != http.MethodPost {
+		return false
+	}
+	switch strings.ToLower(protocol) {
+	case "evm", "sol":
+		return isJSONArray(body)
+	}
+	return false
+}
+
+// serveBatch splits a JSON-RPC batch across candidates, retries failed calls
+// on other upstreams and writes the replies back in the original order.
+func (p *Proxy) serveBatch(w http.ResponseWriter, r *http.Request, network, protocol, tail string, candidates []registry.NodeWithPing, body []byte, start time.Time) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		writeRawJSON(w, http.StatusOK, rpcErrorResponse(nil, rpcCodeParseError, "parse error"))
+		return
+	}
+	if len(raw) == 0 {
+		writeRawJSON(w, http.StatusOK, rpcErrorResponse(nil, rpcCodeInvalidRequest, "empty batch"))
+		return
+	}
+
+	var ad adapters.Result
+	items := make([]*batchItem, len(raw))
+	pending := make([]int, 0, len(raw))
+	for i, msg := range raw {
+		it := &batchItem{}
+		items[i] = it
+
+		var call map[string]json.RawMessage
+		err := json.Unmarshal(msg, &call)
+		if _, ok := parseRPCCall(msg); !ok || err != nil {
+			it.bad = true
+			it.origID = call["id"]
+			if it.origID == nil {
+				it.origID = json.RawMessage("null")
+			}
+			continue
+		}
+		it.origID = call["id"]
+		if c, _ := parseRPCCall(msg); p.blocked(network, c.Method) {
+			it.blocked = true
+			continue
+		}
+		call["id"] = json.RawMessage(strconv.Itoa(i))
+		b, _ := json.Marshal(call)
+
+		ad = protocols.Adapt(network, protocol, candidates[0].URL, tail, http.MethodPost, r.Header, b, p.Logger)
+		it.body = ad.Body
+
+		if c, ok := parseRPCCall(it.body); ok {
+			if it.cacheKey = p.Cache.key(network, c); it.cacheKey != "" {
+				if res, ok := p.Cache.get(network, c.Method, it.cacheKey); ok {
+					it.reply = rpcResultResponse(c.ID, res)
+					it.done = true
+					it.cacheKey = ""
+					continue
+				}
+			}
+			it.tags = p.requiredTags(network, c)
+			it.height = p.referencedHeight(network, c)
+		}
+		pending = append(pending, i)
+	}
+
+	inHeaders := r.Header.Clone()
+	inHeaders.Del(minBlockHeader)
+	for k, v := range ad.Headers {
+		inHeaders.Set(k, v)
+	}
+
+	size := p.Reg.MaxBatchSize(network)
+	if size <= 0 {
+		size = defaultMaxBatchSize
+	}
+	// calls needing tagged nodes go out in their own chunks
+	groups := map[string][]int{}
+	var order []string
+	for _, i := range pending {
+		k := strings.Join(items[i].tags, ",")
+		if _, ok := groups[k]; !ok {
+			order = append(order, k)
+		}
+		groups[k] = append(groups[k], i)
+	}
+
+	var wg sync.WaitGroup
+	c := 0
+	for _, k := range order {
+		idx := groups[k]
+		nodes := withTags(candidates, items[idx[0]].tags)
+		if len(nodes) == 0 {
+			for _, i := range idx {
+				items[i].noRoute = true
+			}
+			p.Logger.Warn("proxy_batch_no_upstreams_with_tags",
+				zap.String("network", network),
+				zap.Strings("tags", items[idx[0]].tags),
+				zap.Int("calls", len(idx)),
+			)
+			continue
+		}
+		for off := 0; off < len(idx); c, off = c+1, off+size {
+			end := off + size
+			if end > len(idx) {
+				end = len(idx)
+			}
+			// each chunk goes to upstreams that have the highest block its calls read at
+			chunkNodes := nodes
+			var need uint64
+			for _, i := range idx[off:end] {
+				need = max(need, items[i].height)
+			}
+			if need > 0 {
+				chunkNodes = p.atHeight(network, nodes, need)
+			}
+			wg.Add(1)
+			go func(chunk int, nodes []registry.NodeWithPing, idx []int) {
+				defer wg.Done()
+				p.runBatchChunk(r.Context(), network, nodes, chunk, items, idx, ad.Tail, r.URL.RawQuery, inHeaders)
+			}(c, chunkNodes, idx[off:end])
+		}
+	}
+	wg.Wait()
+
+	for _, i := range pending {
+		it := items[i]
+		if it.done && it.cacheKey != "" {
+			if c, ok := parseRPCCall(it.body); ok {
+				p.Cache.store(network, c, it.cacheKey, it.reply)
+			}
+		}
+	}
+
+	out := make([]json.RawMessage, 0, len(items))
+	var ok, failed int
+	for _, it := range items {
+		var reply json.RawMessage
+		switch {
+		case it.bad:
+			reply = rpcErrorResponse(it.origID, rpcCodeInvalidRequest, "invalid request")
+		case it.blocked:
+			if it.origID == nil {
+				continue // notification
+			}
+			reply = methodNotAllowed(it.origID)
+		case it.reply != nil:
+			if it.done {
+				ok++
+			} else {
+				failed++
+			}
+			if it.origID == nil {
+				continue // notification
+			}
+			var err error
+			if reply, err = withRPCID(it.reply, it.origID); err != nil {
+				reply = rpcErrorResponse(it.origID, rpcCodeInternalError, "bad upstream reply")
+			}
+		default:
+			failed++
+			if it.origID == nil {
+				continue
+			}
+			msg := "all upstreams failed"
+			if it.noRoute {
+				msg = "no upstreams with required tags"
+			}
+			reply = rpcErrorResponse(it.origID, rpcCodeInternalError, msg)
+		}
+		out = append(out, reply)
+	}
+	metrics.ProxyBatchCalls.WithLabelValues(network, "ok").Add(float64(ok))
+	metrics.ProxyBatchCalls.WithLabelValues(network, "failed").Add(float64(failed))
+
+	if len(out) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	respBody, _ := json.Marshal(out)
+	writeRawJSON(w, http.StatusOK, respBody)
+	LogResponse(p.Logger, "proxy", http.StatusOK, respBody, start)
+
+	p.Logger.Info("proxy_batch_done",
+		zap.String("network", network),
+		zap.Int("calls", len(items)),
+		zap.Int("ok", ok),
+		zap.Int("failed", failed),
+		zap.Int64("latency_ms", time.Since(start).Milliseconds()),
+	)
+	if ok > 0 {
+		metrics.ProxySuccess.WithLabelValues(network).Inc()
+	} else {
+		metrics.ProxyFail.WithLabelValues(network).Inc()
+	}
+}
+
+// runBatchChunk sends the calls in idx, starting on the chunk-th node of the first
+// tier, and moves calls that failed or got a retryable error on to the next candidate.
+func (p *Proxy) runBatchChunk(ctx context.Context, network string, candidates []registry.NodeWithPing, chunk int, items []*batchItem, idx []int, tail, rawQuery string, hdr http.Header) {
+	pending := idx
+	protocol := p.Reg.ProtocolOf(network)
+	candidates = rotateTiers(candidates, chunk)
+	for attempt := 0; attempt < len(candidates) && len(pending) > 0; attempt++ {
+		node := candidates[attempt]
+		upstreamURL := buildUpstreamURL(node.URL, tail, rawQuery)
+
+		var buf bytes.Buffer
+		buf.WriteByte('[')
+		for n, i := range pending {
+			if n > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(items[i].body)
+		}
+		buf.WriteByte(']')
+
+		resp, respBody, err := p.doUpstream(ctx, network, node, http.MethodPost, upstreamURL, hdr, buf.Bytes())
+		if err != nil {
+			p.Logger.Warn("proxy_batch_upstream_error",
+				zap.String("network", network),
+				zap.String("upstream", upstreamURL),
+				zap.Int("calls", len(pending)),
+				zap.Int("attempt", attempt+1),
+				zap.Error(err),
+			)
+			continue
+		}
+		if isRateLimited(resp, respBody) || resp.StatusCode >= 500 {
+			p.Logger.Warn("proxy_batch_upstream_rate_or_5xx",
+				zap.String("network", network),
+				zap.String("upstream", upstreamURL),
+				zap.Int("status", resp.StatusCode),
+				zap.Int("calls", len(pending)),
+				zap.Int("attempt", attempt+1),
+			)
+			continue
+		}
+
+		var replies []json.RawMessage
+		if err := json.Unmarshal(respBody, &replies); err != nil {
+			// e.g. a single error object for "batch too large"
+			p.Logger.Warn("proxy_batch_bad_reply",
+				zap.String("network", network),
+				zap.String("upstream", upstreamURL),
+				zap.Int("status", resp.StatusCode),
+				zap.ByteString("body", LogSafe(respBody)),
+			)
+			continue
+		}
+		byID := make(map[int]json.RawMessage, len(replies))
+		for _, rep := range replies {
+			var m struct {
+				ID json.RawMessage `json:"id"`
+			}
+			if json.Unmarshal(rep, &m) != nil {
+				continue
+			}
+			if id, err := strconv.Atoi(strings.Trim(string(m.ID), `"`)); err == nil {
+				byID[id] = rep
+			}
+		}
+
+		next := make([]int, 0, len(pending))
+		for _, i := range pending {
+			rep, ok := byID[i]
+			if ok {
+				items[i].reply = rep
+			}
+			if !ok || rpcReplyRetryable(protocol, rep) {
+				if ok && classifyRPCReply(protocol, rep) == rpcErrRetryable {
+					items[i].missed = append(items[i].missed, node.URL)
+				}
+				next = append(next, i)
+				continue
+			}
+			items[i].done = true
+			p.blameRetryable(network, items[i].missed)
+		}
+		if len(next) > 0 {
+			p.Logger.Warn("proxy_batch_partial",
+				zap.String("network", network),
+				zap.String("upstream", upstreamURL),
+				zap.Int("retry_calls", len(next)),
+				zap.Int("attempt", attempt+1),
+			)
+		}
+		pending = next
+	}
+}
+
+func writeRawJSON(w http.ResponseWriter, code int, body []byte) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+// echoRPC answers every call of a batch with its method name as result.
+func echoRPC(t *testing.T, rateLimit map[string]bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var calls []map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(body, &calls))
+		out := make([]map[string]any, 0, len(calls))
+		for _, c := range calls {
+			var method string
+			_ = json.Unmarshal(c["method"], &method)
+			if rateLimit[method] {
+				out = append(out, map[string]any{"jsonrpc": "2.0", "id": c["id"], "error": map[string]any{"code": -32005, "message": "rate limit exceeded"}})
+				continue
+			}
+			out = append(out, map[string]any{"jsonrpc": "2.0", "id": c["id"], "result": method})
+		}
+		_ = json.NewEncoder(w).Encode(out)
+	}))
+}
+
+func TestServeBatch_RetriesAndKeepsOrder(t *testing.T) {
+	limited := echoRPC(t, map[string]bool{"eth_call": true})
+	defer limited.Close()
+	good := echoRPC(t, nil)
+	defer good.Close()
+
+	reg := registry.New()
+	reg.AddNetwork(networks.NetworkConfig{Route: "/eth", Protocol: "evm", MaxBatchSize: 2}, []registry.NodeWithPing{
+		{Node: networks.Node{URL: limited.URL, Priority: 1}, Alive: true},
+		{Node: networks.Node{URL: good.URL, Priority: 2}, Alive: true},
+	})
+	p := NewProxy(reg, zap.NewNop(), "")
+
+	body := `[
+		{"jsonrpc":"2.0","id":"a","method":"eth_chainId"},
+		{"jsonrpc":"2.0","id":7,"method":"eth_call"},
+		{"jsonrpc":"2.0","method":"eth_blockNumber"},
+		{"jsonrpc":"2.0","id":9},
+		{"jsonrpc":"2.0","id":[1],"method":"eth_gasPrice"}
+	]`
+	rec := httptest.NewRecorder()
+	p.Serve(rec, httptest.NewRequest(http.MethodPost, "/eth", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var out []struct {
+		ID     json.RawMessage `json:"id"`
+		Result string          `json:"result"`
+		Error  *rpcError       `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &out))
+	require.Len(t, out, 4, "notification must not get a reply")
+
+	require.JSONEq(t, `"a"`, string(out[0].ID))
+	require.Equal(t, "eth_chainId", out[0].Result)
+	require.JSONEq(t, `7`, string(out[1].ID))
+	require.Equal(t, "eth_call", out[1].Result, "rate-limited call should be retried on another node")
+	require.JSONEq(t, `9`, string(out[2].ID))
+	require.Equal(t, rpcCodeInvalidRequest, out[2].Error.Code)
+	require.JSONEq(t, `[1]`, string(out[3].ID))
+	require.Equal(t, "eth_gasPrice", out[3].Result)
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/metrics"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/secrets"
+)
+
+// allowed drops candidates that are cooling down after a rate limit, whose circuit is
+// open or that were ejected as outliers. Cooldowns are strict: if every node is
+// throttled nothing is returned, along with the time until the first one is usable
+// again. If every remaining node is tripped or ejected the list is kept: trying a bad
+// node beats failing the request outright. A tripped node is only kept by the request
+// that claims its half-open trial, so concurrent requests don't all go through as trials.
+func (p *Proxy) allowed(network string, candidates []registry.NodeWithPing) ([]registry.NodeWithPing, time.Duration) {
+	ready := make([]registry.NodeWithPing, 0, len(candidates))
+	var wait time.Duration
+	for _, n := range candidates {
+		if until, ok := p.Reg.Stats(n.URL).CooldownUntil(); ok {
+			if d := time.Until(until); wait == 0 || d < wait {
+				wait = d
+			}
+			continue
+		}
+		ready = append(ready, n)
+	}
+	if len(ready) == 0 {
+		return nil, wait
+	}
+
+	out := make([]registry.NodeWithPing, 0, len(ready))
+	for _, n := range ready {
+		stats := p.Reg.Stats(n.URL)
+		if _, ejected := stats.EjectedUntil(); ejected {
+			continue
+		}
+		prev := stats.Breaker()
+		if !stats.Ready() {
+			continue
+		}
+		if prev == registry.BreakerOpen {
+			setCircuitGauge(network, n.URL, registry.BreakerHalfOpen)
+		}
+		out = append(out, n)
+	}
+	if len(out) == 0 {
+		p.Logger.Warn("proxy_all_circuits_open", zap.String("network", network))
+		return ready, 0
+	}
+	return out, 0
+}
+
+// coolDown takes a throttled upstream out of rotation for the provider's hint, or the
+// registry backoff when there is none.
+func (p *Proxy) coolDown(network, url string, stats *registry.NodeStats, hint time.Duration) {
+	d := stats.CoolDown(hint)
+	p.Logger.Warn("upstream_cooldown",
+		zap.String("network", network),
+		zap.String("upstream", secrets.RedactString(url)),
+		zap.Duration("for", d),
+		zap.Bool("provider_hint", hint > 0),
+	)
+	metrics.UpstreamCooldowns.WithLabelValues(network).Inc()
+}
+
+func (p *Proxy) recordFailure(network, url string, stats *registry.NodeStats) {
+	prev := stats.Breaker()
+	state := stats.Failure()
+	if state != prev {
+		p.Logger.Warn("circuit_opened",
+			zap.String("network", network),
+			zap.String("upstream", secrets.RedactString(url)),
+		)
+	}
+	setCircuitGauge(network, url, state)
+}
+
+func (p *Proxy) recordSuccess(network, url string, stats *registry.NodeStats) {
+	if prev := stats.Success(); prev != registry.BreakerClosed {
+		p.Logger.Info("circuit_closed",
+			zap.String("network", network),
+			zap.String("upstream", secrets.RedactString(url)),
+		)
+		setCircuitGauge(network, url, registry.BreakerClosed)
+	}
+}
+
+// observe feeds a reply into the upstream's health score and ejects the upstream once
+// it stands out as an outlier.
+func (p *Proxy) observe(network, url string, stats *registry.NodeStats, o registry.Outcome) {
+	stats.Observe(o)
+	if !stats.Outlier() {
+		return
+	}
+	d := stats.Eject()
+	p.Logger.Warn("upstream_ejected",
+		zap.String("network", network),
+		zap.String("upstream", secrets.RedactString(url)),
+		zap.Duration("for", d),
+	)
+	metrics.UpstreamEjections.WithLabelValues(network).Inc()
+}
+
+// blameRetryable counts the retryable errors of urls against their scores once another
+// upstream has answered the same request: the error was the node's, not the request's.
+func (p *Proxy) blameRetryable(network string, urls []string) {
+	for _, url := range urls {
+		p.observe(network, url, p.Reg.Stats(url), registry.OutcomeRPCError)
+	}
+}
+
+func setCircuitGauge(network, url string, state registry.BreakerState) {
+	metrics.CircuitState.WithLabelValues(network, secrets.RedactString(url)).Set(float64(state))
+}
@@ -0,0 +1,271 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/sha3"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/metrics"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+const defaultBroadcastFanout = 3
+
+// broadcastPaths are REST endpoints that submit a signed transaction.
+var broadcastPaths = map[string]struct{}{
+	"wallet/broadcasttransaction": {}, // TRON
+	"wallet/broadcasthex":         {},
+	"tx":                          {}, // Esplora (Blockstream) tx push
+	"api/tx":                      {},
+}
+
+// isBroadcast reports whether the request submits a transaction: a JSON-RPC write
+// method or a POST to a known tx push endpoint.
+func isBroadcast(upstreamMethod, tail string, call rpcCall, isCall bool) bool {
+	if isCall {
+		return isWriteMethod(call.Method)
+	}
+	if upstreamMethod != http.MethodPost {
+		return false
+	}
+	t := strings.ToLower(strings.Trim(tail, "/"))
+	if _, ok := broadcastPaths[t]; ok {
+		return true
+	}
+	return strings.HasSuffix(t, "/broadcast") // Tatum v3/{chain}/broadcast
+}
+
+type txOutcome string
+
+const (
+	txAccepted txOutcome = "accepted" // upstream returned the tx hash
+	txKnown    txOutcome = "known"    // already known: the tx reached the network before
+	txRejected txOutcome = "rejected" // definitive error reply, e.g. insufficient funds
+	txFailed   txOutcome = "failed"   // transport error, rate limit or 5xx
+)
+
+// knownTxMarkers are error fragments nodes use for a transaction they have already seen.
+// "nonce too low" is not one: it equally means another tx with that nonce was mined.
+var knownTxMarkers = []string{
+	"already known",
+	"known transaction",
+	"already imported",
+	"already been processed",
+	"alreadyprocessed",
+	"already in block chain",
+	"txn-already-in-mempool",
+	"txn-already-known",
+	"dup_transaction",
+}
+
+func looksLikeKnownTx(s string) bool {
+	s = strings.ToLower(s)
+	for _, m := range knownTxMarkers {
+		if strings.Contains(s, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyBroadcast sorts an upstream reply to a tx submission and extracts the tx hash.
+func classifyBroadcast(res *proxyResult) (txOutcome, string) {
+	if res == nil || res.retryable {
+		return txFailed, ""
+	}
+	var j map[string]json.RawMessage
+	if json.Unmarshal(res.body, &j) != nil {
+		// Esplora answers with the bare txid as text
+		if res.status < 300 {
+			return txAccepted, strings.TrimSpace(string(res.body))
+		}
+		if looksLikeKnownTx(string(res.body)) {
+			return txKnown, ""
+		}
+		return txRejected, ""
+	}
+	if e, ok := j["error"]; ok && string(e) != "null" {
+		if looksLikeKnownTx(string(e)) {
+			return txKnown, ""
+		}
+		return txRejected, ""
+	}
+	// TRON reports failures as {"code":"...","message":"..."}
+	if c, ok := j["code"]; ok && looksLikeKnownTx(string(c)) {
+		return txKnown, ""
+	}
+	for _, k := range []string{"result", "txid", "txId", "hash"} {
+		var h string
+		if json.Unmarshal(j[k], &h) == nil && h != "" {
+			return txAccepted, h
+		}
+	}
+	if string(j["result"]) == "true" && res.status < 300 {
+		return txAccepted, ""
+	}
+	return txRejected, ""
+}
+
+// localTxHash computes the hash of a signed EVM transaction (keccak256 of the raw tx), so
+// an "already known" reply can be answered with it. Other chains return "" and rely on a
+// hash returned by an upstream.
+func localTxHash(protocol string, call rpcCall) string {
+	if protocol != "evm" || call.Method != "eth_sendRawTransaction" {
+		return ""
+	}
+	var params []string
+	if json.Unmarshal(call.Params, &params) != nil || len(params) == 0 {
+		return ""
+	}
+	raw, err := hex.DecodeString(strings.TrimPrefix(params[0], "0x"))
+	if err != nil || len(raw) == 0 {
+		return ""
+	}
+	h := sha3.NewLegacyKeccak256()
+	h.Write(raw)
+	return "0x" + hex.EncodeToString(h.Sum(nil))
+}
+
+// knownTxResult answers a JSON-RPC submission the network already has as a success
+// carrying the tx hash.
+func knownTxResult(id json.RawMessage, hash, upstream string) *proxyResult {
+	result, _ := json.Marshal(hash)
+	hdr := http.Header{}
+	hdr.Set("content-type", "application/json")
+	return &proxyResult{status: http.StatusOK, header: hdr, body: rpcResultResponse(id, result), upstream: upstream}
+}
+
+type broadcastReply struct {
+	res      *proxyResult
+	outcome  txOutcome
+	hash     string
+	upstream string
+}
+
+// forwardBroadcast sends a transaction to the first fanout candidates at once and
+// returns the first reply carrying a tx hash. The other sends are not cancelled;
+// their replies are collected and logged. If every send failed, the remaining
+// candidates are tried one by one.
+func (p *Proxy) forwardBroadcast(ctx context.Context, up upstreamRequest, candidates []registry.NodeWithPing, start time.Time, fanout int) *proxyResult {
+	n := min(fanout, len(candidates))
+	replies := make(chan broadcastReply, n)
+	// the tx must reach every upstream even if the client goes away
+	sendCtx := context.WithoutCancel(ctx)
+	for i, node := range candidates[:n] {
+		go func() {
+			res := p.attempt(sendCtx, up, node, i, start)
+			o, h := classifyBroadcast(res)
+			replies <- broadcastReply{res: res, outcome: o, hash: h, upstream: node.URL}
+		}()
+	}
+
+	var id json.RawMessage
+	var local string
+	call, isCall := parseRPCCall(up.body)
+	if isCall {
+		id = call.ID
+		local = localTxHash(p.Reg.ProtocolOf(up.network), call)
+	}
+
+	won := make(chan *proxyResult, 1)
+	go p.collectBroadcast(up.network, n, isCall, id, local, replies, won)
+
+	select {
+	case res := <-won:
+		if res != nil {
+			return res
+		}
+	case <-ctx.Done():
+		return nil
+	}
+
+	var fallback *proxyResult
+	for i, node := range candidates[n:] {
+		res := p.attempt(ctx, up, node, n+i, start)
+		if res != nil && res.retryable {
+			fallback = res
+			continue
+		}
+		if res != nil {
+			return res
+		}
+	}
+	return fallback
+}
+
+// collectBroadcast reads all n replies. It hands the first accepted reply to won right
+// away. A JSON-RPC "already known" reply is turned into a success with the tx hash,
+// computed locally (local) or returned by another upstream. Otherwise the best other
+// reply is handed over once all are in (known before rejected), or nil if every send
+// failed.
+func (p *Proxy) collectBroadcast(network string, n int, isCall bool, id json.RawMessage, local string, replies <-chan broadcastReply, won chan<- *proxyResult) {
+	counts := map[txOutcome]int{}
+	hash := local
+	var fallback *broadcastReply
+	sent := false
+	for range n {
+		r := <-replies
+		counts[r.outcome]++
+		metrics.ProxyBroadcasts.WithLabelValues(network, string(r.outcome)).Inc()
+		switch r.outcome {
+		case txAccepted:
+			if hash == "" {
+				hash = r.hash
+			} else if r.hash != "" && !strings.EqualFold(r.hash, hash) {
+				p.Logger.Warn("proxy_broadcast_hash_mismatch",
+					zap.String("network", network),
+					zap.String("hash", hash),
+					zap.String("other_hash", r.hash),
+				)
+			}
+			if !sent {
+				won <- r.res
+				sent = true
+			}
+		case txKnown:
+			if !sent && isCall && hash != "" {
+				won <- knownTxResult(id, hash, r.upstream)
+				sent = true
+			}
+			if fallback == nil || fallback.outcome == txRejected {
+				fallback = &r
+			}
+		case txRejected:
+			if fallback == nil {
+				fallback = &r
+			}
+		}
+	}
+	if !sent {
+		switch {
+		case fallback == nil:
+			won <- nil
+		case fallback.outcome == txKnown && isCall && hash != "":
+			won <- knownTxResult(id, hash, fallback.upstream)
+		default:
+			won <- fallback.res
+		}
+	}
+
+	// known replies only confirm the tx when its hash is known
+	confirmed := counts[txAccepted]
+	if hash != "" {
+		confirmed += counts[txKnown]
+	}
+	p.Logger.Info("proxy_broadcast_done",
+		zap.String("network", network),
+		zap.Int("upstreams", n),
+		zap.Int("confirmed", confirmed),
+		zap.Int("accepted", counts[txAccepted]),
+		zap.Int("known", counts[txKnown]),
+		zap.Int("rejected", counts[txRejected]),
+		zap.Int("failed", counts[txFailed]),
+		zap.String("hash", hash),
+	)
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+func TestServe_BroadcastsTransactions(t *testing.T) {
+	var hits atomic.Int32
+	reply := func(status int, body string, delay time.Duration) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			hits.Add(1)
+			time.Sleep(delay)
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+		}))
+	}
+	broken := reply(http.StatusBadGateway, "", 0)
+	defer broken.Close()
+	known := reply(http.StatusOK, `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"already known"}}`, 0)
+	defer known.Close()
+	accepting := reply(http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":"0xabc"}`, 20*time.Millisecond)
+	defer accepting.Close()
+	spare := reply(http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":"0xabc"}`, 0)
+	defer spare.Close()
+
+	reg := registry.New()
+	reg.AddNetwork(networks.NetworkConfig{
+		Route:     "/eth",
+		Protocol:  "evm",
+		Strategy:  networks.StrategyPriorityFailover,
+		Broadcast: networks.BroadcastConfig{Enabled: true, Fanout: 3},
+	}, []registry.NodeWithPing{
+		{Node: networks.Node{URL: broken.URL, Priority: 1}, Alive: true},
+		{Node: networks.Node{URL: known.URL, Priority: 2}, Alive: true},
+		{Node: networks.Node{URL: accepting.URL, Priority: 3}, Alive: true},
+		{Node: networks.Node{URL: spare.URL, Priority: 4}, Alive: true},
+	})
+	p := NewProxy(reg, zap.NewNop(), "")
+
+	rec := httptest.NewRecorder()
+	p.Serve(rec, httptest.NewRequest(http.MethodPost, "/eth",
+		strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_sendRawTransaction","params":["0xf86c"]}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	hash := localTxHash("evm", rpcCall{Method: "eth_sendRawTransaction", Params: []byte(`["0xf86c"]`)})
+	require.Contains(t, rec.Body.String(), `"`+hash+`"`, "an early 'already known' is answered with the tx hash")
+	require.EqualValues(t, 3, hits.Load(), "only fanout upstreams get the tx")
+}
+
+func TestServe_AnswersKnownTransactionsWithHash(t *testing.T) {
+	known := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":7,"error":{"code":-32000,"message":"already known"}}`))
+	}))
+	defer known.Close()
+
+	reg := registry.New()
+	reg.AddNetwork(networks.NetworkConfig{
+		Route:     "/eth",
+		Protocol:  "evm",
+		Broadcast: networks.BroadcastConfig{Enabled: true, Fanout: 2},
+	}, []registry.NodeWithPing{{Node: networks.Node{URL: known.URL, Priority: 1}, Alive: true}})
+	p := NewProxy(reg, zap.NewNop(), "")
+
+	rec := httptest.NewRecorder()
+	p.Serve(rec, httptest.NewRequest(http.MethodPost, "/eth",
+		strings.NewReader(`{"jsonrpc":"2.0","id":7,"method":"eth_sendRawTransaction","params":["0x616263"]}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	// keccak256("abc")
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":7,"result":"0x4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45"}`, rec.Body.String())
+}
+
+func TestClassifyBroadcast(t *testing.T) {
+	res := func(status int, body string) *proxyResult { return &proxyResult{status: status, body: []byte(body)} }
+	cases := []struct {
+		res     *proxyResult
+		outcome txOutcome
+		hash    string
+	}{
+		{res(200, `{"result":"0x1"}`), txAccepted, "0x1"},
+		{res(200, `{"error":{"message":"already known"}}`), txKnown, ""},
+		{res(200, `{"error":{"message":"nonce too low"}}`), txRejected, ""},
+		{res(200, `{"error":{"message":"insufficient funds"}}`), txRejected, ""},
+		{res(200, `{"result":true,"txid":"ff00"}`), txAccepted, "ff00"},
+		{res(200, `{"code":"DUP_TRANSACTION_ERROR","message":"dup"}`), txKnown, ""},
+		{res(200, "a1b2c3\n"), txAccepted, "a1b2c3"},
+		{res(400, `sendrawtransaction RPC error: {"code":-27,"message":"Transaction already in block chain"}`), txKnown, ""},
+		{nil, txFailed, ""},
+	}
+	for _, c := range cases {
+		o, h := classifyBroadcast(c.res)
+		require.Equal(t, c.outcome, o)
+		require.Equal(t, c.hash, h)
+	}
+
+	require.True(t, isBroadcast(http.MethodPost, "/wallet/broadcasttransaction", rpcCall{}, false))
+	require.True(t, isBroadcast(http.MethodPost, "v3/bitcoin/broadcast", rpcCall{}, false))
+	require.False(t, isBroadcast(http.MethodGet, "tx", rpcCall{}, false))
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/cache"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/metrics"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+const (
+	defaultCacheEntries  = 10000
+	defaultCacheHeadTTL  = time.Second
+	defaultFinalityDepth = 64
+)
+
+// cacheableMethods lists the JSON-RPC methods the cache knows how to handle.
+// Whether a particular reply is actually stored is decided by cacheTTL.
+var cacheableMethods = map[string]struct{}{
+	"eth_chainId":               {},
+	"net_version":               {},
+	"eth_getTransactionReceipt": {},
+	"eth_getBlockByHash":        {},
+	"eth_getBlockByNumber":      {},
+	"eth_blockNumber":           {},
+	"eth_gasPrice":              {},
+}
+
+// responseCache keeps JSON-RPC results per network for networks that opted in via `cache.enabled`.
+type responseCache struct {
+	reg   *registry.Registry
+	mu    sync.Mutex
+	lrus  map[string]*cache.LRU
+	heads map[string]uint64 // latest block number seen per network
+}
+
+func newResponseCache(reg *registry.Registry) *responseCache {
+	return &responseCache{reg: reg, lrus: map[string]*cache.LRU{}, heads: map[string]uint64{}}
+}
+
+// key returns the cache key for call, or "" if the call must not be cached.
+func (c *responseCache) key(network string, call rpcCall) string {
+	if !c.reg.CacheConfig(network).Enabled {
+		return ""
+	}
+	if _, ok := cacheableMethods[call.Method]; !ok {
+		return ""
+	}
+	params := normalizeParams(call.Params)
+	return network + "|" + call.Method + "|" + params
+}
+
+// get returns the cached result for key and records a hit or miss.
+func (c *responseCache) get(network, method, key string) (json.RawMessage, bool) {
+	res, ok := c.lru(network).Get(key)
+	if ok {
+		metrics.CacheHits.WithLabelValues(network, method).Inc()
+	} else {
+		metrics.CacheMisses.WithLabelValues(network, method).Inc()
+	}
+	return res, ok
+}
+
+// store saves the result of a successful reply if the method's policy allows it.
+func (c *responseCache) store(network string, call rpcCall, key string, reply []byte) {
+	var r struct {
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(reply, &r) != nil || len(r.Error) > 0 || len(r.Result) == 0 || string(r.Result) == "null" {
+		return
+	}
+	if call.Method == "eth_blockNumber" {
+		if n, ok := parseHexQuantity(r.Result); ok {
+			c.observeHead(network, n)
+		}
+	}
+	ttl, ok := c.ttl(network, call, r.Result)
+	if !ok {
+		return
+	}
+	c.lru(network).Set(key, r.Result, ttl)
+}
+
+// ttl decides how long a result stays cached: 0 means until evicted.
+func (c *responseCache) ttl(network string, call rpcCall, result json.RawMessage) (time.Duration, bool) {
+	cfg := c.reg.CacheConfig(network)
+	headTTL := time.Duration(cfg.HeadTTLMs) * time.Millisecond
+	if headTTL <= 0 {
+		headTTL = defaultCacheHeadTTL
+	}
+
+	switch call.Method {
+	case "eth_chainId", "net_version", "eth_getBlockByHash":
+		return 0, true
+	case "eth_blockNumber", "eth_gasPrice":
+		return headTTL, true
+	case "eth_getTransactionReceipt":
+		var rc struct {
+			BlockNumber json.RawMessage `json:"blockNumber"`
+		}
+		if json.Unmarshal(result, &rc) != nil {
+			return 0, false
+		}
+		n, ok := parseHexQuantity(rc.BlockNumber)
+		if !ok {
+			return 0, false // pending
+		}
+		// mined but not final yet: a reorg may still move it
+		if !c.isFinal(network, n) {
+			return headTTL, true
+		}
+		return 0, true
+	case "eth_getBlockByNumber":
+		var params []json.RawMessage
+		if json.Unmarshal(call.Params, &params) != nil || len(params) == 0 {
+			return 0, false
+		}
+		n, ok := parseHexQuantity(params[0]) // tags like "latest" are never cached
+		if !ok || !c.isFinal(network, n) {
+			return 0, false
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// isFinal reports whether block n is at least finalityDepth below the latest known head.
+func (c *responseCache) isFinal(network string, n uint64) bool {
+	depth := uint64(c.reg.CacheConfig(network).FinalityDepth)
+	if depth == 0 {
+		depth = defaultFinalityDepth
+	}
+	head, _ := c.head(network)
+	return head >= depth && n <= head-depth
+}
+
+func (c *responseCache) observeHead(network string, n uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n > c.heads[network] {
+		c.heads[network] = n
+	}
+}
+
+// head returns the latest block number seen for the network, either in proxied
+// eth_blockNumber replies or by the health checker.
+func (c *responseCache) head(network string) (uint64, bool) {
+	c.mu.Lock()
+	n := c.heads[network]
+	c.mu.Unlock()
+	n = max(n, c.reg.Head(network))
+	return n, n > 0
+}
+
+// forget drops the entries and head of a network.
+func (c *responseCache) forget(network string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.lrus, network)
+	delete(c.heads, network)
+}
+
+func (c *responseCache) lru(network string) *cache.LRU {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, ok := c.lrus[network]
+	if !ok {
+		size := c.reg.CacheConfig(network).MaxEntries
+		if size <= 0 {
+			size = defaultCacheEntries
+		}
+		l = cache.NewLRU(size)
+		c.lrus[network] = l
+	}
+	return l
+}
+
+// parseHexQuantity decodes a JSON string like "0x1b4".
+func parseHexQuantity(raw json.RawMessage) (uint64, bool) {
+	var s string
+	if json.Unmarshal(raw, &s) != nil || !strings.HasPrefix(s, "0x") {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s[2:], 16, 64)
+	return n, err == nil
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+func TestServe_CachesImmutableResults(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+	defer srv.Close()
+
+	reg := registry.New()
+	reg.AddNetwork(networks.NetworkConfig{
+		Route:    "/eth",
+		Protocol: "evm",
+		Cache:    networks.CacheConfig{Enabled: true},
+	}, []registry.NodeWithPing{{Node: networks.Node{URL: srv.URL, Priority: 1}, Alive: true}})
+	p := NewProxy(reg, zap.NewNop(), "")
+
+	for _, id := range []string{"1", `"x"`} {
+		rec := httptest.NewRecorder()
+		body := `{"jsonrpc":"2.0","id":` + id + `,"method":"eth_chainId","params":[]}`
+		p.Serve(rec, httptest.NewRequest(http.MethodPost, "/eth", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Contains(t, rec.Body.String(), `"result":"0x1"`)
+		require.Contains(t, rec.Body.String(), `"id":`+id)
+	}
+	require.EqualValues(t, 1, calls.Load(), "second eth_chainId must be served from cache")
+
+	// block numbers above the finalized head are never cached
+	rc := p.Cache
+	rc.observeHead("eth", 1000)
+	call := rpcCall{Method: "eth_getBlockByNumber", Params: []byte(`["0x3e8",false]`)}
+	_, ok := rc.ttl("eth", call, []byte(`{}`))
+	require.False(t, ok)
+	call.Params = []byte(`["0x10",false]`)
+	_, ok = rc.ttl("eth", call, []byte(`{}`))
+	require.True(t, ok)
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+)
+
+// writeMethods submit transactions; they are never coalesced, hedged or put to a quorum.
+var writeMethods = map[string]struct{}{
+	"eth_sendRawTransaction": {},
+	"eth_sendTransaction":    {},
+	"sendTransaction":        {},
+	"sendrawtransaction":     {},
+}
+
+func isWriteMethod(method string) bool {
+	_, ok := writeMethods[method]
+	return ok
+}
+
+// idempotentReads are the JSON-RPC methods (path.Match patterns) whose reply depends
+// only on the call and the chain, so concurrent identical calls may share one upstream
+// request. Anything else, e.g. filters (eth_newFilter, eth_getFilterChanges) and
+// subscriptions, holds per-client state on the node and goes upstream on its own.
+var idempotentReads = []string{
+	// evm
+	"eth_blockNumber", "eth_chainId", "eth_syncing", "eth_protocolVersion",
+	"eth_gasPrice", "eth_maxPriorityFeePerGas", "eth_feeHistory", "eth_blobBaseFee",
+	"eth_getBalance", "eth_getCode", "eth_getStorageAt", "eth_getTransactionCount", "eth_getProof",
+	"eth_call", "eth_estimateGas", "eth_createAccessList", "eth_getLogs",
+	"eth_getBlockByNumber", "eth_getBlockByHash", "eth_getBlockReceipts",
+	"eth_getBlockTransactionCountBy*", "eth_getUncleCountBy*", "eth_getUncleBy*",
+	"eth_getTransactionByHash", "eth_getTransactionBy*AndIndex", "eth_getTransactionReceipt",
+	"net_version", "net_listening", "net_peerCount", "web3_clientVersion",
+	"debug_trace*", "trace_*",
+	// sol: every getX call, plus the read-only calls outside that naming
+	"get[A-Z]*", "isBlockhashValid", "minimumLedgerSlot", "simulateTransaction",
+	// btc, ltc, doge
+	"getbestblockhash", "getblock", "getblockchaininfo", "getblockcount", "getblockhash", "getblockheader",
+	"getblockstats", "getchaintips", "getdifficulty", "getmempoolinfo", "getrawmempool", "getmempoolentry",
+	"getrawtransaction", "gettxout", "getnetworkinfo", "estimatesmartfee", "decoderawtransaction",
+}
+
+func isIdempotentRead(method string) bool {
+	return networks.MatchMethod(idempotentReads, method)
+}
+
+// flightGroup deduplicates identical upstream requests that are in flight at the same time.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+type flight struct {
+	done chan struct{}
+	res  *proxyResult
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{calls: map[string]*flight{}}
+}
+
+// do runs fn once per key; callers arriving while it runs wait for and share its result.
+func (g *flightGroup) do(key string, fn func() *proxyResult) (res *proxyResult, shared bool) {
+	g.mu.Lock()
+	if f, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-f.done
+		return f.res, true
+	}
+	f := &flight{done: make(chan struct{})}
+	g.calls[key] = f
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(f.done)
+	}()
+	f.res = fn()
+	return f.res, false
+}
+
+// coalesceKey normalizes a single JSON-RPC read so that requests differing only by id
+// share a key. Returns "" for anything that must go upstream on its own.
+func coalesceKey(up upstreamRequest, call rpcCall, isCall bool) string {
+	if !isCall || up.method != http.MethodPost || !isIdempotentRead(call.Method) || up.quorum > 0 {
+		return ""
+	}
+	params := normalizeParams(call.Params)
+	return up.network + "|" + up.tail + "|" + up.rawQuery + "|" + call.Method + "|" + params + "|" + strconv.FormatUint(up.minBlock, 10)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+func TestServe_CoalescesIdenticalRequests(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		var call rpcCall
+		_ = json.NewDecoder(r.Body).Decode(&call)
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(call.ID) + `,"result":"0x10"}`))
+	}))
+	defer srv.Close()
+
+	reg := registry.New()
+	reg.AddNetwork(networks.NetworkConfig{Route: "/eth", Protocol: "evm"},
+		[]registry.NodeWithPing{{Node: networks.Node{URL: srv.URL, Priority: 1}, Alive: true}})
+	p := NewProxy(reg, zap.NewNop(), "")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			body := `{"jsonrpc":"2.0","id":` + strconv.Itoa(id) + `,"method":"eth_blockNumber"}`
+			p.Serve(rec, httptest.NewRequest(http.MethodPost, "/eth", strings.NewReader(body)))
+
+			var out struct {
+				ID     int    `json:"id"`
+				Result string `json:"result"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &out))
+			require.Equal(t, "0x10", out.Result)
+			require.Equal(t, id, out.ID, "every client gets its own id back")
+		}(i)
+	}
+	wg.Wait()
+	require.Less(t, calls.Load(), int32(10), "identical requests should share upstream calls")
+}
+
+func TestCoalesceKey_OnlyIdempotentReads(t *testing.T) {
+	up := upstreamRequest{network: "eth", method: http.MethodPost}
+	for _, method := range []string{"eth_blockNumber", "eth_call", "eth_getTransactionByBlockHashAndIndex", "debug_traceTransaction", "getAccountInfo", "getblockcount"} {
+		require.NotEmpty(t, coalesceKey(up, rpcCall{Method: method}, true), method)
+	}
+	for _, method := range []string{
+		"eth_newFilter", "eth_newBlockFilter", "eth_newPendingTransactionFilter",
+		"eth_getFilterChanges", "eth_getFilterLogs", "eth_uninstallFilter",
+		"eth_subscribe", "eth_unsubscribe", "eth_sendRawTransaction", "debug_setHead", "getnewaddress",
+	} {
+		require.Empty(t, coalesceKey(up, rpcCall{Method: method}, true), method)
+	}
+}
+
+func TestServe_DoesNotCoalesceFilters(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		var call rpcCall
+		_ = json.NewDecoder(r.Body).Decode(&call)
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(call.ID) + `,"result":"0x` + strconv.Itoa(int(n)) + `"}`))
+	}))
+	defer srv.Close()
+
+	reg := registry.New()
+	reg.AddNetwork(networks.NetworkConfig{Route: "/eth", Protocol: "evm"},
+		[]registry.NodeWithPing{{Node: networks.Node{URL: srv.URL, Priority: 1}, Alive: true}})
+	p := NewProxy(reg, zap.NewNop(), "")
+
+	var mu sync.Mutex
+	ids := map[string]bool{}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			p.Serve(rec, httptest.NewRequest(http.MethodPost, "/eth",
+				strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_newBlockFilter"}`)))
+			var out struct {
+				Result string `json:"result"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &out))
+			mu.Lock()
+			ids[out.Result] = true
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	require.EqualValues(t, 5, calls.Load())
+	require.Len(t, ids, 5, "every client gets its own filter")
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/metrics"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+const defaultHedgeDelay = 250 * time.Millisecond
+
+// isHedgeable reports whether a request is a read that may be sent to several upstreams:
+// plain GETs and JSON-RPC calls from the idempotentReads allowlist. Writes, filters and
+// subscriptions, as well as REST POSTs (e.g. TRON wallet/broadcasttransaction), are never hedged.
+func isHedgeable(clientMethod, upstreamMethod string, call rpcCall, isCall bool) bool {
+	if isCall {
+		return isIdempotentRead(call.Method)
+	}
+	return clientMethod == http.MethodGet && upstreamMethod == http.MethodGet
+}
+
+type hedgeAttempt struct {
+	res   *proxyResult
+	node  string
+	hedge bool
+}
+
+// forwardHedged starts with the first candidate and fires the next one whenever
+// the attempts in flight haven't answered within delay (or one of them failed).
+// The first acceptable reply wins; the remaining attempts are cancelled.
+func (p *Proxy) forwardHedged(ctx context.Context, up upstreamRequest, candidates []registry.NodeWithPing, start time.Time, delay time.Duration) *proxyResult {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgeAttempt, len(candidates))
+	next, inflight := 0, 0
+	launch := func(hedge bool) {
+		i, node := next, candidates[next]
+		next++
+		inflight++
+		go func() {
+			results <- hedgeAttempt{res: p.attempt(ctx, up, node, i, start), node: node.URL, hedge: hedge}
+		}()
+	}
+
+	var fallback *proxyResult
+	var missed []string
+	launch(false)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for inflight > 0 {
+		select {
+		case <-timer.C:
+			if next < len(candidates) {
+				metrics.ProxyHedges.WithLabelValues(up.network).Inc()
+				p.Logger.Debug("proxy_hedge_fired",
+					zap.String("network", up.network),
+					zap.Int("attempt", next+1),
+				)
+				launch(true)
+				timer.Reset(delay)
+			}
+		case a := <-results:
+			inflight--
+			if a.res != nil && a.res.retryable {
+				fallback = a.res
+				missed = append(missed, a.node)
+				a.res = nil
+			}
+			if a.res != nil {
+				p.blameRetryable(up.network, missed)
+				if a.hedge {
+					metrics.ProxyHedgeWins.WithLabelValues(up.network).Inc()
+				}
+				return a.res
+			}
+			// failed fast: move on without waiting for the timer
+			if next < len(candidates) {
+				launch(false)
+				timer.Reset(delay)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+func TestServe_HedgesSlowReads(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"slow"}`))
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"fast"}`))
+	}))
+	defer fast.Close()
+
+	reg := registry.New()
+	reg.AddNetwork(networks.NetworkConfig{
+		Route:     "/eth",
+		Protocol:  "evm",
+		TimeoutMs: 2000,
+		Hedge:     networks.HedgeConfig{Enabled: true, DelayMs: 20},
+	}, []registry.NodeWithPing{
+		{Node: networks.Node{URL: slow.URL, Priority: 1}, Alive: true},
+		{Node: networks.Node{URL: fast.URL, Priority: 2}, Alive: true},
+	})
+	p := NewProxy(reg, zap.NewNop(), "")
+
+	begin := time.Now()
+	rec := httptest.NewRecorder()
+	p.Serve(rec, httptest.NewRequest(http.MethodPost, "/eth", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_call","params":[]}`)))
+	require.Contains(t, rec.Body.String(), `"fast"`)
+	require.Less(t, time.Since(begin), 500*time.Millisecond)
+
+	// writes and filters stay on the node that got them
+	for _, body := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"eth_sendRawTransaction","params":["0x00"]}`,
+		`{"jsonrpc":"2.0","id":1,"method":"eth_newFilter","params":[{}]}`,
+		`{"jsonrpc":"2.0","id":1,"method":"eth_getFilterChanges","params":["0x1"]}`,
+	} {
+		rec = httptest.NewRecorder()
+		p.Serve(rec, httptest.NewRequest(http.MethodPost, "/eth", strings.NewReader(body)))
+		require.Contains(t, rec.Body.String(), `"slow"`, body)
+	}
+}
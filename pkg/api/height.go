@@ -0,0 +1,222 @@
+package api
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/cache"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/metrics"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+// minBlockHeader pins the lowest block height ("19000000" or "0x121eac0") the serving
+// upstream must have.
+const minBlockHeader = "x-rpc-min-block"
+
+const defaultBlockIndexEntries = 50000
+
+// blockParamIndex is the position of the block number, tag or hash in the params of
+// JSON-RPC methods that read at a given block. eth_getLogs is handled separately.
+var blockParamIndex = map[string]int{
+	"eth_getBlockByNumber":                    0,
+	"eth_getBlockByHash":                      0,
+	"eth_getBlockReceipts":                    0,
+	"eth_getBlockTransactionCountByNumber":    0,
+	"eth_getBlockTransactionCountByHash":      0,
+	"eth_getTransactionByBlockNumberAndIndex": 0,
+	"eth_getTransactionByBlockHashAndIndex":   0,
+	"eth_getUncleCountByBlockNumber":          0,
+	"eth_getUncleCountByBlockHash":            0,
+	"eth_getUncleByBlockNumberAndIndex":       0,
+	"eth_getUncleByBlockHashAndIndex":         0,
+	"debug_traceBlockByNumber":                0,
+	"debug_traceBlockByHash":                  0,
+	"trace_block":                             0,
+	"eth_getBalance":                          1,
+	"eth_getCode":                             1,
+	"eth_getTransactionCount":                 1,
+	"eth_call":                                1,
+	"eth_estimateGas":                         1,
+	"debug_traceCall":                         1,
+	"eth_getStorageAt":                        2,
+	"eth_getProof":                            2,
+}
+
+// blockIndex remembers block hash → number pairs seen in proxied replies, so calls
+// that reference a block by hash can be routed like calls by number.
+type blockIndex struct {
+	lru *cache.LRU
+}
+
+func newBlockIndex() *blockIndex {
+	return &blockIndex{lru: cache.NewLRU(defaultBlockIndexEntries)}
+}
+
+func (b *blockIndex) add(network, hash string, n uint64) {
+	b.lru.Set(network+"|"+strings.ToLower(hash), []byte(strconv.FormatUint(n, 10)), 0)
+}
+
+func (b *blockIndex) lookup(network, hash string) (uint64, bool) {
+	v, ok := b.lru.Get(network + "|" + strings.ToLower(hash))
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(string(v), 10, 64)
+	return n, err == nil
+}
+
+// parseBlockHeight decodes a decimal or 0x-prefixed hex block height.
+func parseBlockHeight(s string) (uint64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+	var n uint64
+	var err error
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		n, err = strconv.ParseUint(s[2:], 16, 64)
+	} else {
+		n, err = strconv.ParseUint(s, 10, 64)
+	}
+	return n, err == nil
+}
+
+func isBlockHash(s string) bool {
+	return len(s) == 66 && strings.HasPrefix(s, "0x")
+}
+
+// referencedHeight returns the highest block a call reads at, resolving hashes seen
+// before; 0 means the call doesn't name a block, or only by tag ("latest", ...).
+func (p *Proxy) referencedHeight(network string, call rpcCall) uint64 {
+	var refs []json.RawMessage
+	if call.Method == "eth_getLogs" {
+		var params []struct {
+			FromBlock json.RawMessage `json:"fromBlock"`
+			ToBlock   json.RawMessage `json:"toBlock"`
+			BlockHash json.RawMessage `json:"blockHash"`
+		}
+		if json.Unmarshal(call.Params, &params) != nil || len(params) == 0 {
+			return 0
+		}
+		refs = []json.RawMessage{params[0].FromBlock, params[0].ToBlock, params[0].BlockHash}
+	} else {
+		i, ok := blockParamIndex[call.Method]
+		if !ok {
+			return 0
+		}
+		var params []json.RawMessage
+		if json.Unmarshal(call.Params, &params) != nil || len(params) <= i {
+			return 0
+		}
+		refs = []json.RawMessage{params[i]}
+	}
+
+	var height uint64
+	for _, ref := range refs {
+		if len(ref) == 0 {
+			continue
+		}
+		height = max(height, p.resolveBlockRef(network, ref))
+	}
+	return height
+}
+
+// resolveBlockRef decodes a block parameter: a quantity, a hash, or an EIP-1898 object.
+func (p *Proxy) resolveBlockRef(network string, ref json.RawMessage) uint64 {
+	var obj struct {
+		BlockNumber json.RawMessage `json:"blockNumber"`
+		BlockHash   json.RawMessage `json:"blockHash"`
+	}
+	if json.Unmarshal(ref, &obj) == nil {
+		if len(obj.BlockNumber) > 0 {
+			return p.resolveBlockRef(network, obj.BlockNumber)
+		}
+		if len(obj.BlockHash) > 0 {
+			return p.resolveBlockRef(network, obj.BlockHash)
+		}
+		return 0
+	}
+	var s string
+	if json.Unmarshal(ref, &s) != nil {
+		return 0
+	}
+	if isBlockHash(s) {
+		n, _ := p.Blocks.lookup(network, s)
+		return n
+	}
+	if n, ok := parseHexQuantity(ref); ok {
+		return n
+	}
+	return 0 // a tag
+}
+
+// observeReply learns upstream heads and block hashes from a successful JSON-RPC reply:
+// the upstream has at least the block it returned, or the head it reported.
+func (p *Proxy) observeReply(network, upstream string, reqBody, respBody []byte) {
+	call, ok := parseRPCCall(reqBody)
+	if !ok || !strings.HasPrefix(call.Method, "eth_") {
+		return
+	}
+	var r struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if json.Unmarshal(respBody, &r) != nil || len(r.Result) == 0 {
+		return
+	}
+	if call.Method == "eth_blockNumber" {
+		if n, ok := parseHexQuantity(r.Result); ok {
+			p.Reg.Stats(upstream).ObserveHead(n)
+		}
+		return
+	}
+	// blocks carry number/hash; transactions and receipts blockNumber/blockHash
+	var obj struct {
+		Number      json.RawMessage `json:"number"`
+		Hash        string          `json:"hash"`
+		BlockNumber json.RawMessage `json:"blockNumber"`
+		BlockHash   string          `json:"blockHash"`
+	}
+	if json.Unmarshal(r.Result, &obj) != nil {
+		return
+	}
+	num, hash := obj.BlockNumber, obj.BlockHash
+	if len(obj.Number) > 0 && isBlockHash(obj.Hash) {
+		num, hash = obj.Number, obj.Hash
+	}
+	n, ok := parseHexQuantity(num)
+	if !ok || !isBlockHash(hash) {
+		return // pending
+	}
+	p.Reg.Stats(upstream).ObserveHead(n)
+	p.Blocks.add(network, hash, n)
+}
+
+// atHeight keeps the candidates that have block n, or whose head is unknown. When no
+// candidate is known to have it yet, all of them are kept, highest head first.
+func (p *Proxy) atHeight(network string, candidates []registry.NodeWithPing, n uint64) []registry.NodeWithPing {
+	out := make([]registry.NodeWithPing, 0, len(candidates))
+	for _, c := range candidates {
+		if h := p.Reg.Stats(c.URL).Head(); h == 0 || h >= n {
+			out = append(out, c)
+		}
+	}
+	if skipped := len(candidates) - len(out); skipped > 0 {
+		metrics.ProxyHeightSkips.WithLabelValues(network).Add(float64(skipped))
+	}
+	if len(out) > 0 {
+		return out
+	}
+	p.Logger.Debug("proxy_no_upstreams_at_height",
+		zap.String("network", network),
+		zap.Uint64("height", n),
+	)
+	out = append(out, candidates...)
+	sort.SliceStable(out, func(i, j int) bool {
+		return p.Reg.Stats(out[i].URL).Head() > p.Reg.Stats(out[j].URL).Head()
+	})
+	return out
+}
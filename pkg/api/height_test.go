@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+func TestServe_RoutesByBlockHeight(t *testing.T) {
+	behind := namedRPC("behind")
+	defer behind.Close()
+	ahead := namedRPC("ahead")
+	defer ahead.Close()
+
+	reg := registry.New()
+	reg.AddNetwork(networks.NetworkConfig{
+		Route:    "/eth",
+		Protocol: "evm",
+		Strategy: networks.StrategyPriorityFailover,
+	}, []registry.NodeWithPing{
+		{Node: networks.Node{URL: behind.URL, Priority: 1}, Alive: true},
+		{Node: networks.Node{URL: ahead.URL, Priority: 2}, Alive: true},
+	})
+	reg.Stats(behind.URL).SetHead(100)
+	reg.Stats(ahead.URL).SetHead(200)
+	p := NewProxy(reg, zap.NewNop(), "")
+	hash := "0x" + strings.Repeat("ab", 32)
+	p.Blocks.add("eth", hash, 180)
+
+	call := func(body, minBlock string) string {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/eth", strings.NewReader(body))
+		if minBlock != "" {
+			req.Header.Set(minBlockHeader, minBlock)
+		}
+		p.Serve(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec.Body.String()
+	}
+	require.Contains(t, call(`{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["latest",false]}`, ""), `"behind"`)
+	require.Contains(t, call(`{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["0x50",false]}`, ""), `"behind"`)
+	require.Contains(t, call(`{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["0x96",false]}`, ""), `"ahead"`)
+	require.Contains(t, call(`{"jsonrpc":"2.0","id":1,"method":"eth_getBalance","params":["0x1",{"blockNumber":"0xc8"}]}`, ""), `"ahead"`)
+	require.Contains(t, call(`{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByHash","params":["`+hash+`",false]}`, ""), `"ahead"`)
+	require.Contains(t, call(`{"jsonrpc":"2.0","id":1,"method":"eth_getLogs","params":[{"fromBlock":"0x10","toBlock":"0xa0"}]}`, ""), `"ahead"`)
+	require.Contains(t, call(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}`, "150"), `"ahead"`)
+	require.Contains(t, call(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}`, "0x96"), `"ahead"`)
+	// no upstream has the block yet: the most advanced one is asked first
+	require.Contains(t, call(`{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["0x1000",false]}`, ""), `"ahead"`)
+}
+
+func TestServeBatch_RoutesByBlockHeight(t *testing.T) {
+	// answers every call of a batch with the upstream's name
+	named := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			var calls []map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal(body, &calls))
+			out := make([]map[string]any, 0, len(calls))
+			for _, c := range calls {
+				out = append(out, map[string]any{"jsonrpc": "2.0", "id": c["id"], "result": name})
+			}
+			_ = json.NewEncoder(w).Encode(out)
+		}))
+	}
+	behind := named("behind")
+	defer behind.Close()
+	ahead := named("ahead")
+	defer ahead.Close()
+
+	reg := registry.New()
+	reg.AddNetwork(networks.NetworkConfig{
+		Route:        "/eth",
+		Protocol:     "evm",
+		Strategy:     networks.StrategyPriorityFailover,
+		MaxBatchSize: 1,
+	}, []registry.NodeWithPing{
+		{Node: networks.Node{URL: behind.URL, Priority: 1}, Alive: true},
+		{Node: networks.Node{URL: ahead.URL, Priority: 2}, Alive: true},
+	})
+	reg.Stats(behind.URL).SetHead(100)
+	reg.Stats(ahead.URL).SetHead(200)
+	p := NewProxy(reg, zap.NewNop(), "")
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/eth", strings.NewReader(`[
+		{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["0x50",false]},
+		{"jsonrpc":"2.0","id":2,"method":"eth_getBlockByNumber","params":["0x96",false]}
+	]`))
+	p.Serve(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var replies []struct {
+		Result string `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &replies))
+	require.Len(t, replies, 2)
+	require.Equal(t, "behind", replies[0].Result)
+	require.Equal(t, "ahead", replies[1].Result, "a chunk reading past a node's head skips it")
+}
+
+func TestObserveReply(t *testing.T) {
+	reg := registry.New()
+	p := NewProxy(reg, zap.NewNop(), "")
+	hash := "0x" + strings.Repeat("cd", 32)
+
+	p.observeReply("eth", "http://a", []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"}`), []byte(`{"jsonrpc":"2.0","id":1,"result":"0x12c"}`))
+	require.EqualValues(t, 300, reg.Stats("http://a").Head())
+
+	// an older block doesn't lower the head, but its hash is learned
+	p.observeReply("eth", "http://a", []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["0x64",false]}`),
+		[]byte(`{"jsonrpc":"2.0","id":1,"result":{"number":"0x64","hash":"`+hash+`"}}`))
+	require.EqualValues(t, 300, reg.Stats("http://a").Head())
+	n, ok := p.Blocks.lookup("eth", "0x"+strings.Repeat("CD", 32))
+	require.True(t, ok)
+	require.EqualValues(t, 100, n)
+
+	// pending blocks have no hash
+	p.observeReply("eth", "http://b", []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["pending",false]}`),
+		[]byte(`{"jsonrpc":"2.0","id":1,"result":{"number":"0x200","hash":null}}`))
+	require.Zero(t, reg.Stats("http://b").Head())
+}
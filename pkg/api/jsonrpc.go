@@ -0,0 +1,97 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	rpcCodeParseError     = -32700
+	rpcCodeInvalidRequest = -32600
+	rpcCodeMethodNotFound = -32601
+	rpcCodeInternalError  = -32603
+	rpcCodeNoQuorum       = -32050 // server-defined: upstreams disagree on a quorum read
+)
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcErrorReply struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   rpcError        `json:"error"`
+}
+
+// rpcErrorResponse builds a JSON-RPC error reply; a nil id is encoded as null.
+func rpcErrorResponse(id json.RawMessage, code int, msg string) json.RawMessage {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	b, _ := json.Marshal(rpcErrorReply{JSONRPC: "2.0", ID: id, Error: rpcError{Code: code, Message: msg}})
+	return b
+}
+
+type rpcCall struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type rpcResultReply struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result"`
+}
+
+// parseRPCCall decodes a single JSON-RPC call; ok is false for batches, REST bodies
+// and objects without a method.
+func parseRPCCall(body []byte) (rpcCall, bool) {
+	var call rpcCall
+	if len(body) == 0 || json.Unmarshal(body, &call) != nil || call.Method == "" {
+		return rpcCall{}, false
+	}
+	return call, true
+}
+
+// normalizeParams returns compact params so that formatting differences don't matter; absent params are "[]".
+func normalizeParams(params json.RawMessage) string {
+	var buf bytes.Buffer
+	if len(params) > 0 && json.Compact(&buf, params) == nil && buf.String() != "null" {
+		return buf.String()
+	}
+	return "[]"
+}
+
+// rpcResultResponse builds a successful JSON-RPC reply.
+func rpcResultResponse(id, result json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	b, _ := json.Marshal(rpcResultReply{JSONRPC: "2.0", ID: id, Result: result})
+	return b
+}
+
+// withRPCID returns msg with its "id" member replaced by id.
+func withRPCID(msg json.RawMessage, id json.RawMessage) (json.RawMessage, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return nil, err
+	}
+	m["id"] = id
+	return json.Marshal(m)
+}
+
+// rpcReplyRetryable reports whether a single JSON-RPC reply carries an error another node may not have.
+func rpcReplyRetryable(protocol string, reply json.RawMessage) bool {
+	c := classifyRPCReply(protocol, reply)
+	return c == rpcErrRetryable || c == rpcErrNodeBroken
+}
+
+func isJSONArray(body []byte) bool {
+	s := strings.TrimSpace(string(body))
+	return strings.HasPrefix(s, "[")
+}
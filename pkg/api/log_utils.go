@@ -10,7 +10,10 @@ const LogBodyLimit = 4096 // 4  TODO could be a customized via envVar
 
 func LogSafe(b []byte) []byte {
 	if len(b) > LogBodyLimit {
-		return append(b[:LogBodyLimit], []byte("... [truncated]")...)
+		// copy: appending to b[:LogBodyLimit] would overwrite the caller's body
+		out := make([]byte, 0, LogBodyLimit+16)
+		out = append(out, b[:LogBodyLimit]...)
+		return append(out, []byte("... [truncated]")...)
 	}
 	return b
 }
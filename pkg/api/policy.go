@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/metrics"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+// methodBlocked reports whether the network's method policy forbids method, and counts it if so.
+func methodBlocked(reg *registry.Registry, logger *zap.Logger, network, method string) bool {
+	if reg.MethodPolicy(network).Permits(method) {
+		return false
+	}
+	logger.Warn("proxy_method_blocked",
+		zap.String("network", network),
+		zap.String("method", method),
+	)
+	metrics.ProxyBlockedCalls.WithLabelValues(network).Inc()
+	return true
+}
+
+func (p *Proxy) blocked(network, method string) bool {
+	return methodBlocked(p.Reg, p.Logger, network, method)
+}
+
+// methodNotAllowed is the reply to a call rejected by the method policy.
+func methodNotAllowed(id json.RawMessage) json.RawMessage {
+	return rpcErrorResponse(id, rpcCodeMethodNotFound, "method not allowed")
+}
+
+func (w *WS) rejectBlocked(network string, msg []byte) []byte {
+	return rejectBlocked(w.Reg, w.Logger, network, msg)
+}
+
+// rejectBlocked checks a message forwarded as is (websocket, or a batch on a route
+// without fan-out) against the method policy. A batch with a blocked call is refused as
+// a whole, since its replies can't be merged with upstream ones. It returns the reply to
+// send back, or nil if the message may be forwarded.
+func rejectBlocked(reg *registry.Registry, logger *zap.Logger, network string, msg []byte) []byte {
+	if c, ok := parseRPCCall(msg); ok {
+		if methodBlocked(reg, logger, network, c.Method) {
+			return methodNotAllowed(c.ID)
+		}
+		return nil
+	}
+	var calls []rpcCall
+	if !isJSONArray(msg) || json.Unmarshal(msg, &calls) != nil {
+		return nil
+	}
+	blocked := make([]bool, len(calls))
+	var found bool
+	for i, c := range calls {
+		if c.Method != "" && methodBlocked(reg, logger, network, c.Method) {
+			blocked[i], found = true, true
+		}
+	}
+	if !found {
+		return nil
+	}
+	out := make([]json.RawMessage, 0, len(calls))
+	for i, c := range calls {
+		if blocked[i] {
+			out = append(out, methodNotAllowed(c.ID))
+		} else {
+			out = append(out, rpcErrorResponse(c.ID, rpcCodeInvalidRequest, "batch contains a method that is not allowed"))
+		}
+	}
+	b, _ := json.Marshal(out)
+	return b
+}
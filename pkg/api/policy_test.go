@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+func TestServe_MethodPolicy(t *testing.T) {
+	up := namedRPC("upstream")
+	defer up.Close()
+
+	reg := registry.New()
+	reg.AddNetwork(networks.NetworkConfig{
+		Route:    "/eth",
+		Protocol: "evm",
+		Methods: networks.MethodPolicy{
+			Allow: []string{"eth_*", "net_*", "debug_*"},
+			Deny:  []string{"debug_*", "eth_sign*"},
+		},
+	}, []registry.NodeWithPing{{Node: networks.Node{URL: up.URL, Priority: 1}, Alive: true}})
+	p := NewProxy(reg, zap.NewNop(), "")
+
+	call := func(body string) []byte {
+		rec := httptest.NewRecorder()
+		p.Serve(rec, httptest.NewRequest(http.MethodPost, "/eth", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec.Body.Bytes()
+	}
+	var single rpcErrorReply
+	require.NoError(t, json.Unmarshal(call(`{"jsonrpc":"2.0","id":5,"method":"admin_peers"}`), &single))
+	require.Equal(t, rpcCodeMethodNotFound, single.Error.Code)
+	require.JSONEq(t, `5`, string(single.ID))
+	require.Contains(t, string(call(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}`)), `"upstream"`)
+
+	var out []struct {
+		ID     json.RawMessage `json:"id"`
+		Result string          `json:"result"`
+		Error  *rpcError       `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(call(`[
+		{"jsonrpc":"2.0","id":1,"method":"eth_chainId"},
+		{"jsonrpc":"2.0","id":2,"method":"debug_traceTransaction","params":["0xabc"]},
+		{"jsonrpc":"2.0","method":"eth_sign"},
+		{"jsonrpc":"2.0","id":3,"method":"net_version"}
+	]`), &out))
+	require.Len(t, out, 3)
+	require.Equal(t, "upstream", out[0].Result)
+	require.Equal(t, rpcCodeMethodNotFound, out[1].Error.Code)
+	require.JSONEq(t, `2`, string(out[1].ID))
+	require.Equal(t, "upstream", out[2].Result)
+
+	// routes without batch fan-out forward a batch as is, so a blocked call refuses it
+	var hits int
+	btc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte(`[]`))
+	}))
+	defer btc.Close()
+	reg.AddNetwork(networks.NetworkConfig{
+		Route:    "/btc",
+		Protocol: "btc",
+		Methods:  networks.MethodPolicy{Deny: []string{"dumpprivkey"}},
+	}, []registry.NodeWithPing{{Node: networks.Node{URL: btc.URL, Priority: 1}, Alive: true}})
+	rec := httptest.NewRecorder()
+	p.Serve(rec, httptest.NewRequest(http.MethodPost, "/btc", strings.NewReader(
+		`[{"jsonrpc":"1.0","id":1,"method":"getblockcount"},{"jsonrpc":"1.0","id":2,"method":"dumpprivkey","params":["addr"]}]`)))
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &out))
+	require.Len(t, out, 2)
+	require.Equal(t, rpcCodeInvalidRequest, out[0].Error.Code)
+	require.Equal(t, rpcCodeMethodNotFound, out[1].Error.Code)
+	require.Zero(t, hits, "nothing from the batch reaches the upstream")
+
+	ws := NewWS(reg, zap.NewNop())
+	require.Nil(t, ws.rejectBlocked("eth", []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["newHeads"]}`)))
+	require.Contains(t, string(ws.rejectBlocked("eth", []byte(`[{"jsonrpc":"2.0","id":1,"method":"eth_chainId"},{"jsonrpc":"2.0","id":2,"method":"miner_start"}]`))), `-32601`)
+}
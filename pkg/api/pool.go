@@ -0,0 +1,139 @@
+package api
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+// balancer orders candidates according to the network's strategy. Tiers are
+// always tried in priority order; the strategy only decides the order inside a tier.
+type balancer struct {
+	reg  *registry.Registry
+	mu   sync.Mutex
+	next map[string]int // round-robin cursor per network
+}
+
+func newBalancer(reg *registry.Registry) *balancer {
+	return &balancer{reg: reg, next: map[string]int{}}
+}
+
+// degradedScore is the share of the best score in its tier below which a node is
+// tried after its healthy tier mates.
+const degradedScore = 0.75
+
+// order applies the network's strategy, then moves degraded nodes to the end of their tier.
+func (b *balancer) order(network string, nodes []registry.NodeWithPing) []registry.NodeWithPing {
+	return b.perTier(b.byStrategy(network, nodes), b.demoteDegraded)
+}
+
+func (b *balancer) byStrategy(network string, nodes []registry.NodeWithPing) []registry.NodeWithPing {
+	switch b.reg.Strategy(network) {
+	case networks.StrategyPriorityFailover:
+		// Best is already sorted by priority and ping
+		return nodes
+	case networks.StrategyWeightedRandom:
+		return b.perTier(nodes, weightedShuffle)
+	case networks.StrategyLeastLatency:
+		return b.perTier(nodes, b.byLatency)
+	case networks.StrategyLeastInflight:
+		return b.perTier(nodes, b.byInflight)
+	default:
+		b.mu.Lock()
+		n := b.next[network]
+		b.next[network] = n + 1
+		b.mu.Unlock()
+		return rotateTiers(nodes, n)
+	}
+}
+
+func (b *balancer) perTier(nodes []registry.NodeWithPing, sortTier func([]registry.NodeWithPing)) []registry.NodeWithPing {
+	out := make([]registry.NodeWithPing, 0, len(nodes))
+	for _, tier := range registry.Tiers(nodes) {
+		sortTier(tier)
+		out = append(out, tier...)
+	}
+	return out
+}
+
+// byLatency sorts by observed proxy latency; nodes without samples fall back to their health ping.
+func (b *balancer) byLatency(tier []registry.NodeWithPing) {
+	lat := make(map[string]float64, len(tier))
+	for _, n := range tier {
+		if ms, ok := b.reg.Stats(n.URL).LatencyMs(); ok {
+			lat[n.URL] = ms
+		} else {
+			lat[n.URL] = float64(n.Ping)
+		}
+	}
+	sort.SliceStable(tier, func(i, j int) bool { return lat[tier[i].URL] < lat[tier[j].URL] })
+}
+
+// demoteDegraded moves nodes scoring well below the best of their tier to its end,
+// best score first; the others keep the strategy's order.
+func (b *balancer) demoteDegraded(tier []registry.NodeWithPing) {
+	scores := make(map[string]float64, len(tier))
+	best := 0.0
+	for _, n := range tier {
+		s := b.reg.Stats(n.URL).Score()
+		scores[n.URL] = s
+		best = max(best, s)
+	}
+	degraded := func(n registry.NodeWithPing) bool { return scores[n.URL] < best*degradedScore }
+	sort.SliceStable(tier, func(i, j int) bool {
+		di, dj := degraded(tier[i]), degraded(tier[j])
+		if di != dj {
+			return dj
+		}
+		return di && scores[tier[i].URL] > scores[tier[j].URL]
+	})
+}
+
+func (b *balancer) byInflight(tier []registry.NodeWithPing) {
+	inflight := make(map[string]int64, len(tier))
+	for _, n := range tier {
+		inflight[n.URL] = b.reg.Stats(n.URL).Inflight()
+	}
+	sort.SliceStable(tier, func(i, j int) bool { return inflight[tier[i].URL] < inflight[tier[j].URL] })
+}
+
+// weightedShuffle orders a tier by weighted random sampling without replacement.
+func weightedShuffle(tier []registry.NodeWithPing) {
+	rest := append([]registry.NodeWithPing(nil), tier...)
+	for i := range tier {
+		total := 0
+		for _, n := range rest {
+			total += nodeWeight(n)
+		}
+		pick, k := rand.Intn(total), 0
+		for ; k < len(rest)-1; k++ {
+			pick -= nodeWeight(rest[k])
+			if pick < 0 {
+				break
+			}
+		}
+		tier[i] = rest[k]
+		rest = append(rest[:k], rest[k+1:]...)
+	}
+}
+
+func nodeWeight(n registry.NodeWithPing) int {
+	if n.Weight <= 0 {
+		return 1
+	}
+	return n.Weight
+}
+
+// rotateTiers rotates each priority tier left by n, keeping tiers in priority order.
+func rotateTiers(nodes []registry.NodeWithPing, n int) []registry.NodeWithPing {
+	out := make([]registry.NodeWithPing, 0, len(nodes))
+	for _, tier := range registry.Tiers(nodes) {
+		k := n % len(tier)
+		out = append(out, tier[k:]...)
+		out = append(out, tier[:k]...)
+	}
+	return out
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+func urls(nodes []registry.NodeWithPing) []string {
+	out := make([]string, len(nodes))
+	for i, n := range nodes {
+		out[i] = n.URL
+	}
+	return out
+}
+
+func TestBalancer_Strategies(t *testing.T) {
+	pool := []registry.NodeWithPing{
+		{Node: networks.Node{URL: "a", Priority: 1}, Alive: true, Ping: 10},
+		{Node: networks.Node{URL: "b", Priority: 1}, Alive: true, Ping: 20},
+		{Node: networks.Node{URL: "c", Priority: 2}, Alive: true, Ping: 5},
+	}
+	reg := registry.New()
+	b := newBalancer(reg)
+
+	for _, strategy := range []string{"", networks.StrategyRoundRobin} {
+		reg.AddNetwork(networks.NetworkConfig{Route: "/eth", Strategy: strategy}, nil)
+		first := b.order("eth", pool)
+		second := b.order("eth", pool)
+		require.NotEqual(t, first[0].URL, second[0].URL, "round-robin should rotate inside the tier")
+		require.Equal(t, "c", second[2].URL, "lower priority stays last")
+	}
+
+	reg.AddNetwork(networks.NetworkConfig{Route: "/eth", Strategy: networks.StrategyPriorityFailover}, nil)
+	require.Equal(t, []string{"a", "b", "c"}, urls(b.order("eth", pool)))
+
+	reg.AddNetwork(networks.NetworkConfig{Route: "/eth", Strategy: networks.StrategyLeastLatency}, nil)
+	reg.Stats("a").Begin()
+	reg.Stats("a").End(500 * time.Millisecond)
+	require.Equal(t, []string{"b", "a", "c"}, urls(b.order("eth", pool)))
+
+	reg.AddNetwork(networks.NetworkConfig{Route: "/eth", Strategy: networks.StrategyLeastInflight}, nil)
+	reg.Stats("a").Begin()
+	require.Equal(t, []string{"b", "a", "c"}, urls(b.order("eth", pool)))
+	reg.Stats("a").Abort()
+
+	reg.AddNetwork(networks.NetworkConfig{Route: "/eth", Strategy: networks.StrategyWeightedRandom}, nil)
+	heavy := append([]registry.NodeWithPing(nil), pool...)
+	heavy[1].Weight = 1000
+	wins := 0
+	for i := 0; i < 100; i++ {
+		if b.order("eth", heavy)[0].URL == "b" {
+			wins++
+		}
+	}
+	require.Greater(t, wins, 90)
+}
+
+func TestBalancer_DemotesDegradedNodes(t *testing.T) {
+	pool := []registry.NodeWithPing{
+		{Node: networks.Node{URL: "a", Priority: 1}, Alive: true, Ping: 10},
+		{Node: networks.Node{URL: "b", Priority: 1}, Alive: true, Ping: 20},
+		{Node: networks.Node{URL: "c", Priority: 1}, Alive: true, Ping: 30},
+		{Node: networks.Node{URL: "d", Priority: 2}, Alive: true, Ping: 5},
+	}
+	reg := registry.New()
+	reg.AddNetwork(networks.NetworkConfig{Route: "/eth", Strategy: networks.StrategyPriorityFailover}, nil)
+	b := newBalancer(reg)
+
+	for range 10 {
+		reg.Stats("a").Observe(registry.OutcomeRPCError)
+	}
+	for range 3 {
+		reg.Stats("b").Observe(registry.OutcomeFailed)
+	}
+	// both fail real calls: tried last in their tier, the less broken first; tiers are kept
+	require.Equal(t, []string{"c", "b", "a", "d"}, urls(b.order("eth", pool)))
+}
+
+func TestServe_StopsUsingNodesFailingRealCalls(t *testing.T) {
+	var pruned atomic.Int32
+	// answers eth_blockNumber, but not state reads
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "eth_call") {
+			pruned.Add(1)
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"missing trie node 1a2b (path )"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x10"}`))
+	}))
+	defer broken.Close()
+	good := namedRPC("good")
+	defer good.Close()
+
+	reg := registry.New()
+	reg.AddNetwork(networks.NetworkConfig{Route: "/eth", Protocol: "evm", Strategy: networks.StrategyRoundRobin}, []registry.NodeWithPing{
+		{Node: networks.Node{URL: broken.URL, Priority: 1}, Alive: true},
+		{Node: networks.Node{URL: good.URL, Priority: 1}, Alive: true},
+	})
+	p := NewProxy(reg, zap.NewNop(), "")
+	for range 20 {
+		rec := httptest.NewRecorder()
+		p.Serve(rec, httptest.NewRequest(http.MethodPost, "/eth",
+			strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_call","params":[{"to":"0x1"},"latest"]}`)))
+		require.Contains(t, rec.Body.String(), `"good"`)
+	}
+	require.LessOrEqual(t, pruned.Load(), int32(5), "the failing node should be tried last after a few errors")
+}
+
+func TestServe_DoesNotScoreErrorsNoNodeCanAnswer(t *testing.T) {
+	// full nodes: an archive read fails on all of them
+	pruned := func() *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"missing trie node 1a2b (path )"}}`))
+		}))
+	}
+	a, b := pruned(), pruned()
+	defer a.Close()
+	defer b.Close()
+
+	reg := registry.New()
+	reg.AddNetwork(networks.NetworkConfig{Route: "/eth", Protocol: "evm"}, []registry.NodeWithPing{
+		{Node: networks.Node{URL: a.URL, Priority: 1}, Alive: true},
+		{Node: networks.Node{URL: b.URL, Priority: 1}, Alive: true},
+	})
+	p := NewProxy(reg, zap.NewNop(), "")
+	for range 20 {
+		rec := httptest.NewRecorder()
+		p.Serve(rec, httptest.NewRequest(http.MethodPost, "/eth",
+			strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_call","params":[{"to":"0x1"},"0x1"]}`)))
+		require.Contains(t, rec.Body.String(), "missing trie node")
+	}
+	for _, url := range []string{a.URL, b.URL} {
+		_, ejected := reg.Stats(url).EjectedUntil()
+		require.False(t, ejected, "errors caused by the request must not eject nodes")
+	}
+}
@@ -5,15 +5,17 @@ import (
 	"context"
 	"golang.org/x/net/proxy"
 	"io"
+	"math"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"go.uber.org/zap"
 
-	"github.com/shuliakovsky/rpc-forwarder/pkg/adapters"
 	"github.com/shuliakovsky/rpc-forwarder/pkg/metrics"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/protocols"
 	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
 )
 
@@ -22,6 +24,10 @@ type Proxy struct {
 	Logger   *zap.Logger
 	Client   *http.Client
 	TorSocks string
+	Cache    *responseCache
+	Flights  *flightGroup
+	Balancer *balancer
+	Blocks   *blockIndex
 }
 
 func NewProxy(reg *registry.Registry, logger *zap.Logger, torSocks string) *Proxy {
@@ -30,23 +36,43 @@ func NewProxy(reg *registry.Registry, logger *zap.Logger, torSocks string) *Prox
 		Logger:   logger,
 		Client:   &http.Client{Timeout: 8 * time.Second},
 		TorSocks: torSocks,
+		Cache:    newResponseCache(reg),
+		Flights:  newFlightGroup(),
+		Balancer: newBalancer(reg),
+		Blocks:   newBlockIndex(),
 	}
 }
 
+// Forget drops what the proxy cached for a network that was removed or reconfigured.
+func (p *Proxy) Forget(network string) {
+	p.Cache.forget(network)
+}
+
 // Handle /{network}[/*tail]
 func (p *Proxy) Serve(w http.ResponseWriter, r *http.Request) {
-	// Разбор пути: /{network}/optional/tail...
+	// Разбор пути: /{route}/optional/tail...
 	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
 	if len(parts) < 1 {
 		http.NotFound(w, r)
 		return
 	}
-	network := parts[0]
+	network, ok := p.Reg.Resolve(parts[0])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
 	var tail string
 	if len(parts) > 1 {
 		tail = strings.Join(parts[1:], "/")
 	}
 
+	// Сеть выключена через админку
+	if p.Reg.Disabled(network) {
+		p.Logger.Warn("proxy_network_disabled", zap.String("network", network))
+		http.Error(w, "network "+network+" is disabled", http.StatusServiceUnavailable)
+		return
+	}
+
 	// Получение лучших узлов
 	candidates := p.Reg.Best(network)
 	if len(candidates) == 0 {
@@ -54,6 +80,14 @@ func (p *Proxy) Serve(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "no available nodes", http.StatusServiceUnavailable)
 		return
 	}
+	candidates, wait := p.allowed(network, candidates)
+	if len(candidates) == 0 {
+		p.Logger.Warn("proxy_all_upstreams_cooling_down", zap.String("network", network), zap.Duration("retry_after", wait))
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, "all upstreams are rate limited", http.StatusTooManyRequests)
+		return
+	}
+	candidates = p.Balancer.order(network, candidates)
 
 	// Чтение тела запроса
 	origBody, _ := io.ReadAll(r.Body)
@@ -61,10 +95,31 @@ func (p *Proxy) Serve(w http.ResponseWriter, r *http.Request) {
 
 	start := LogRequest(p.Logger, "proxy", r.Method, r.URL.Path, origBody)
 
-	// 🔧 Адаптация запроса
+	// Политика методов сети (allow/deny); батчи с fan-out проверяются поштучно в serveBatch
 	protocol := p.Reg.ProtocolOf(network)
+	batch := isBatchRequest(r.Method, protocol, origBody)
+	if !batch {
+		if respBody := rejectBlocked(p.Reg, p.Logger, network, origBody); respBody != nil {
+			writeRawJSON(w, http.StatusOK, respBody)
+			LogResponse(p.Logger, "proxy", http.StatusOK, respBody, start)
+			return
+		}
+	}
+
+	// Минимальная высота блока, закреплённая клиентом
+	minBlock, _ := parseBlockHeight(r.Header.Get(minBlockHeader))
+	if minBlock > 0 {
+		candidates = p.atHeight(network, candidates, minBlock)
+	}
+
+	if batch {
+		p.serveBatch(w, r, network, protocol, tail, candidates, origBody, start)
+		return
+	}
+
+	// 🔧 Адаптация запроса
 	baseURL := candidates[0].URL
-	ad := adapters.Adapt(network, protocol, baseURL, tail, r.Method, r.Header, origBody, p.Logger)
+	ad := protocols.Adapt(network, protocol, baseURL, tail, r.Method, r.Header, origBody, p.Logger)
 
 	// Уважение метода: если адаптер переписал GET → POST с телом, убираем query
 	rawQuery := r.URL.RawQuery
@@ -92,92 +147,305 @@ func (p *Proxy) Serve(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Ответ из кэша для неизменяемых JSON-RPC результатов (кроме кворумных чтений)
+	call, isCall := parseRPCCall(ad.Body)
+	quorum := p.quorumSize(network, r.Header, call, isCall)
+	var cacheKey string
+	if isCall && ad.Method == http.MethodPost && quorum == 0 {
+		cacheKey = p.Cache.key(network, call)
+	}
+	if cacheKey != "" {
+		if res, ok := p.Cache.get(network, call.Method, cacheKey); ok {
+			respBody := rpcResultResponse(call.ID, res)
+			writeRawJSON(w, http.StatusOK, respBody)
+			LogResponse(p.Logger, "proxy_cache", http.StatusOK, respBody, start)
+			metrics.ProxySuccess.WithLabelValues(network).Inc()
+			return
+		}
+	}
+
+	// Маршрутизация по тегам узлов (archive, trace, ...)
+	if isCall {
+		if tags := p.requiredTags(network, call); len(tags) > 0 {
+			candidates = withTags(candidates, tags)
+			if len(candidates) == 0 {
+				p.Logger.Warn("proxy_no_upstreams_with_tags",
+					zap.String("network", network),
+					zap.String("method", call.Method),
+					zap.Strings("tags", tags),
+				)
+				http.Error(w, "no upstreams with required tags", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		// Узлы, ещё не получившие запрошенный блок, пропускаются
+		if need := p.referencedHeight(network, call); need > minBlock {
+			candidates = p.atHeight(network, candidates, need)
+		}
+	}
+
 	// Подготовка заголовков
 	inHeaders := r.Header.Clone()
+	inHeaders.Del(quorumHeader)
+	inHeaders.Del(minBlockHeader)
 	for k, v := range ad.Headers {
 		inHeaders.Set(k, v)
 	}
 
-	// Попытки отправки запроса на upstream
-	for i, node := range candidates {
-		upstreamURL := buildUpstreamURL(node.URL, ad.Tail, rawQuery)
-
-		// ⏱ Таймаут на узел
-		perNodeTimeout := time.Duration(p.Reg.TimeoutMs(network)) * time.Millisecond
-		if perNodeTimeout <= 0 {
-			perNodeTimeout = defaultTimeoutFor(network)
-		}
-
-		ctx, cancel := context.WithTimeout(r.Context(), perNodeTimeout)
-		req, _ := http.NewRequestWithContext(ctx, ad.Method, upstreamURL, bytes.NewReader(ad.Body))
-		req.Header = inHeaders.Clone()
-		for k, v := range node.Headers {
-			req.Header.Set(k, v)
-		}
-		if req.Header.Get("content-type") == "" &&
-			(ad.Method == http.MethodPost || ad.Method == http.MethodPut) {
-			req.Header.Set("content-type", "application/json")
-		}
-
-		// Отправка запроса
-		client := p.clientFor(node, perNodeTimeout)
-		resp, err := client.Do(req)
-		if err != nil {
-			cancel()
-			p.Logger.Warn("proxy_upstream_error",
-				zap.String("network", network),
-				zap.String("upstream", upstreamURL),
-				zap.Int("attempt", i+1),
-				zap.Error(err),
-			)
-			metrics.ProxyFail.WithLabelValues(network).Inc()
+	up := upstreamRequest{
+		network:   network,
+		method:    ad.Method,
+		tail:      ad.Tail,
+		rawQuery:  rawQuery,
+		header:    inHeaders,
+		body:      ad.Body,
+		hedge:     isHedgeable(r.Method, ad.Method, call, isCall),
+		broadcast: isBroadcast(ad.Method, ad.Tail, call, isCall),
+		quorum:    quorum,
+		minBlock:  minBlock,
+	}
+
+	// Одинаковые запросы в полёте объединяются: ответ лидера получают все
+	var res *proxyResult
+	shared := false
+	if key := coalesceKey(up, call, isCall); key != "" {
+		res, shared = p.Flights.do(key, func() *proxyResult {
+			// лидер не должен падать из-за отключения своего клиента
+			return p.forward(context.WithoutCancel(r.Context()), up, candidates, start)
+		})
+	} else {
+		res = p.forward(r.Context(), up, candidates, start)
+	}
+
+	if res == nil {
+		// Все попытки исчерпаны
+		p.Logger.Error("proxy_all_upstreams_failed", zap.String("network", network))
+		http.Error(w, "all upstreams failed", http.StatusBadGateway)
+		metrics.ProxyFail.WithLabelValues(network).Inc()
+		return
+	}
+
+	respBody := res.body
+	if shared {
+		metrics.ProxyCoalesced.WithLabelValues(network).Inc()
+		if b, err := withRPCID(respBody, call.ID); err == nil {
+			respBody = b
+		}
+	} else if cacheKey != "" && res.status == http.StatusOK {
+		p.Cache.store(network, call, cacheKey, respBody)
+	}
+
+	// ✅ Успешный ответ
+	for k, vv := range res.header {
+		if shared && strings.EqualFold(k, "Content-Length") {
 			continue
 		}
+		for _, v := range vv {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(res.status)
+	_, _ = w.Write(respBody)
+	metrics.ProxySuccess.WithLabelValues(network).Inc()
+}
 
-		respBody, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		cancel()
+// upstreamRequest is the adapted client request as it's sent to every candidate.
+type upstreamRequest struct {
+	network   string
+	method    string
+	tail      string
+	rawQuery  string
+	header    http.Header
+	body      []byte
+	hedge     bool   // read-only, safe to send to several upstreams at once
+	broadcast bool   // submits a transaction
+	quorum    int    // upstreams that must be asked for a majority answer, 0 = plain read
+	minBlock  uint64 // height pinned by the client, only requests with the same pin are coalesced
+}
 
-		lat := time.Since(start).Milliseconds()
-		LogResponse(p.Logger, "proxy", resp.StatusCode, respBody, start)
+// proxyResult is an upstream reply accepted for the client.
+type proxyResult struct {
+	status   int
+	header   http.Header
+	body     []byte
+	upstream string
+	// retryable marks a node-side JSON-RPC error: it is returned only if no other node answers
+	retryable bool
+}
+
+// forward tries candidates in order and returns the first acceptable reply, or nil if all failed.
+func (p *Proxy) forward(ctx context.Context, up upstreamRequest, candidates []registry.NodeWithPing, start time.Time) *proxyResult {
+	if up.quorum > 0 {
+		return p.forwardQuorum(ctx, up, candidates, start, up.quorum)
+	}
+	if up.broadcast {
+		if bc := p.Reg.BroadcastConfig(up.network); bc.Enabled {
+			fanout := bc.Fanout
+			if fanout <= 0 {
+				fanout = defaultBroadcastFanout
+			}
+			return p.forwardBroadcast(ctx, up, candidates, start, fanout)
+		}
+	}
+	if up.hedge {
+		if hc := p.Reg.HedgeConfig(up.network); hc.Enabled && len(candidates) > 1 {
+			delay := time.Duration(hc.DelayMs) * time.Millisecond
+			if delay <= 0 {
+				delay = defaultHedgeDelay
+			}
+			return p.forwardHedged(ctx, up, candidates, start, delay)
+		}
+	}
 
-		// Проверка на рейт-лимит или 5xx
-		if isRateLimited(resp, respBody) || resp.StatusCode >= 500 {
-			p.Logger.Warn("proxy_upstream_rate_or_5xx",
-				zap.String("network", network),
-				zap.String("upstream", upstreamURL),
-				zap.Int("status", resp.StatusCode),
-				zap.Int("attempt", i+1),
-				zap.Int64("latency_ms", lat),
-			)
-			metrics.ProxyFail.WithLabelValues(network).Inc()
+	// Попытки отправки запроса на upstream
+	var fallback *proxyResult
+	var missed []string
+	for i, node := range candidates {
+		res := p.attempt(ctx, up, node, i, start)
+		if res != nil && res.retryable {
+			fallback = res
+			missed = append(missed, node.URL)
 			continue
 		}
+		if res != nil {
+			p.blameRetryable(up.network, missed)
+			return res
+		}
+	}
+	return fallback
+}
 
-		// ✅ Успешный ответ
-		for k, vv := range resp.Header {
-			for _, v := range vv {
-				w.Header().Add(k, v)
-			}
+// attempt sends up to a single candidate. It returns nil if the upstream failed,
+// was rate limited or answered 5xx, and a retryable result for node-side JSON-RPC
+// errors, so the caller can move on.
+func (p *Proxy) attempt(ctx context.Context, up upstreamRequest, node registry.NodeWithPing, i int, start time.Time) *proxyResult {
+	network := up.network
+	upstreamURL := buildUpstreamURL(node.URL, up.tail, up.rawQuery)
+
+	resp, respBody, err := p.doUpstream(ctx, network, node, up.method, upstreamURL, up.header, up.body)
+	if err != nil {
+		if ctx.Err() != nil {
+			// cancelled by the caller (e.g. a hedge already won), not an upstream failure
+			return nil
 		}
-		w.WriteHeader(resp.StatusCode)
-		_, _ = w.Write(respBody)
+		p.Logger.Warn("proxy_upstream_error",
+			zap.String("network", network),
+			zap.String("upstream", upstreamURL),
+			zap.Int("attempt", i+1),
+			zap.Error(err),
+		)
+		metrics.ProxyFail.WithLabelValues(network).Inc()
+		return nil
+	}
 
-		p.Logger.Info("proxy_success",
+	lat := time.Since(start).Milliseconds()
+	LogResponse(p.Logger, "proxy", resp.StatusCode, respBody, start)
+
+	// Проверка на рейт-лимит или 5xx
+	if isRateLimited(resp, respBody) || resp.StatusCode >= 500 {
+		p.Logger.Warn("proxy_upstream_rate_or_5xx",
 			zap.String("network", network),
 			zap.String("upstream", upstreamURL),
 			zap.Int("status", resp.StatusCode),
 			zap.Int("attempt", i+1),
 			zap.Int64("latency_ms", lat),
 		)
-		metrics.ProxySuccess.WithLabelValues(network).Inc()
-		return
+		metrics.ProxyFail.WithLabelValues(network).Inc()
+		return nil
 	}
 
-	// Все попытки исчерпаны
-	p.Logger.Error("proxy_all_upstreams_failed", zap.String("network", network))
-	http.Error(w, "all upstreams failed", http.StatusBadGateway)
-	metrics.ProxyFail.WithLabelValues(network).Inc()
+	// Ошибка на стороне узла (header not found, timeout, ...): другой узел может ответить
+	if class := classifyRPCReply(p.Reg.ProtocolOf(network), respBody); class == rpcErrRetryable || class == rpcErrNodeBroken {
+		p.Logger.Warn("proxy_upstream_rpc_error",
+			zap.String("network", network),
+			zap.String("upstream", upstreamURL),
+			zap.String("class", class.String()),
+			zap.Int("attempt", i+1),
+			zap.ByteString("body", LogSafe(respBody)),
+		)
+		metrics.ProxyUpstreamRPCErrors.WithLabelValues(network, class.String()).Inc()
+		return &proxyResult{status: resp.StatusCode, header: resp.Header, body: respBody, upstream: upstreamURL, retryable: true}
+	}
+
+	p.observeReply(network, node.URL, up.body, respBody)
+	p.Logger.Info("proxy_success",
+		zap.String("network", network),
+		zap.String("upstream", upstreamURL),
+		zap.Int("status", resp.StatusCode),
+		zap.Int("attempt", i+1),
+		zap.Int64("latency_ms", lat),
+	)
+	return &proxyResult{status: resp.StatusCode, header: resp.Header, body: respBody, upstream: upstreamURL}
+}
+
+// doUpstream performs a single attempt against node with the network's per-node timeout
+// and returns the fully read response body.
+func (p *Proxy) doUpstream(ctx context.Context, network string, node registry.NodeWithPing, method, upstreamURL string, hdr http.Header, body []byte) (*http.Response, []byte, error) {
+	// ⏱ Таймаут на узел
+	perNodeTimeout := time.Duration(p.Reg.TimeoutMs(network)) * time.Millisecond
+	if perNodeTimeout <= 0 {
+		perNodeTimeout = protocols.Timeout(p.Reg.ProtocolOf(network))
+	}
+	ctx, cancel := context.WithTimeout(ctx, perNodeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, upstreamURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header = hdr.Clone()
+	for k, v := range node.Headers {
+		req.Header.Set(k, v)
+	}
+	if req.Header.Get("content-type") == "" &&
+		(method == http.MethodPost || method == http.MethodPut) {
+		req.Header.Set("content-type", "application/json")
+	}
+
+	stats := p.Reg.Stats(node.URL)
+	if prev := stats.Breaker(); prev != registry.BreakerClosed {
+		// a request sent to a tripped node without a trial (every circuit open) still counts as one
+		if state := stats.Dispatch(); state != prev {
+			setCircuitGauge(network, node.URL, state)
+		}
+	}
+	stats.Begin()
+	began := time.Now()
+	resp, err := p.clientFor(node, perNodeTimeout).Do(req)
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			stats.Abort()
+		} else {
+			stats.End(time.Since(began))
+			p.recordFailure(network, node.URL, stats)
+			p.observe(network, node.URL, stats, registry.OutcomeFailed)
+		}
+		return nil, nil, err
+	}
+	respBody, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	stats.End(time.Since(began))
+	outcome := registry.OutcomeOK
+	if isRateLimited(resp, respBody) {
+		outcome = registry.OutcomeRateLimited
+		p.coolDown(network, node.URL, stats, retryAfter(resp))
+		p.recordFailure(network, node.URL, stats)
+	} else if resp.StatusCode >= 500 {
+		outcome = registry.OutcomeFailed
+		p.recordFailure(network, node.URL, stats)
+	} else if class := classifyRPCReply(p.Reg.ProtocolOf(network), respBody); class == rpcErrNodeBroken {
+		outcome = registry.OutcomeRPCError
+		p.recordFailure(network, node.URL, stats)
+	} else {
+		p.recordSuccess(network, node.URL, stats)
+		if class == rpcErrRetryable {
+			// often caused by the request itself (archive reads, result limits): it counts
+			// against the score only once another upstream answers it, see blameRetryable
+			return resp, respBody, nil
+		}
+	}
+	p.observe(network, node.URL, stats, outcome)
+	return resp, respBody, nil
 }
 
 func (p *Proxy) clientFor(node registry.NodeWithPing, timeout time.Duration) *http.Client {
@@ -209,18 +477,3 @@ func buildUpstreamURL(base, tail, rawQuery string) string {
 	}
 	return u
 }
-
-func defaultTimeoutFor(network string) time.Duration {
-	switch strings.ToLower(network) {
-	case "sol":
-		return 800 * time.Millisecond
-	case "eth", "evm", "bsc", "polygon", "fantom":
-		return 1500 * time.Millisecond
-	case "trx":
-		return 1500 * time.Millisecond
-	case "btc", "doge", "ltc":
-		return 2000 * time.Millisecond
-	default:
-		return 1500 * time.Millisecond
-	}
-}
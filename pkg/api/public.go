@@ -92,6 +92,7 @@ func (p *Public) ActiveNodes(w http.ResponseWriter, r *http.Request) {
 		type liteNode struct {
 			URL      string `json:"url"`
 			Priority int    `json:"priority"`
+			Ping     int64  `json:"ping"`
 		}
 		out := make(map[string][]liteNode)
 		for name, st := range p.Reg.All() {
@@ -104,6 +105,7 @@ func (p *Public) ActiveNodes(w http.ResponseWriter, r *http.Request) {
 				arr = append(arr, liteNode{
 					URL:      secrets.RedactString(n.URL),
 					Priority: n.Priority,
+					Ping:     n.Ping,
 				})
 			}
 			out[name] = arr
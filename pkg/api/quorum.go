@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/metrics"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/secrets"
+)
+
+const defaultQuorumSize = 3
+
+// maxQuorumSize caps the size a client may ask for on a network without quorum.size.
+const maxQuorumSize = 5
+
+// quorumHeader asks for a quorum read ("3", or any other value for the network default)
+// and reports the agreement ("2/3") on the reply. "0" or "1" opts out of a configured quorum.
+const quorumHeader = "x-rpc-quorum"
+
+// quorumSize returns how many upstreams a call must be read from; 0 means a plain read.
+// A size asked for in the header is capped at quorum.size, or maxQuorumSize if unset.
+func (p *Proxy) quorumSize(network string, hdr http.Header, call rpcCall, isCall bool) int {
+	if !isCall || isWriteMethod(call.Method) {
+		return 0
+	}
+	cfg := p.Reg.QuorumConfig(network)
+	size := cfg.Size
+	if size <= 0 {
+		size = defaultQuorumSize
+	}
+	if v := strings.TrimSpace(hdr.Get(quorumHeader)); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return size
+		}
+		if n < 2 {
+			return 0
+		}
+		limit := cfg.Size
+		if limit <= 0 {
+			limit = maxQuorumSize
+		}
+		return min(n, limit)
+	}
+	if networks.MatchMethod(cfg.Methods, call.Method) {
+		return size
+	}
+	return 0
+}
+
+type quorumVote struct {
+	res      *proxyResult
+	upstream string
+	answer   string
+}
+
+// quorumAnswer reduces a reply to what upstreams must agree on: the canonical result,
+// or the error code (messages differ between node clients).
+func quorumAnswer(body []byte) string {
+	var r struct {
+		Result json.RawMessage `json:"result"`
+		Error  *rpcError       `json:"error"`
+	}
+	if json.Unmarshal(body, &r) != nil {
+		return "raw:" + strings.TrimSpace(string(body))
+	}
+	if r.Error != nil {
+		return "error:" + strconv.Itoa(r.Error.Code)
+	}
+	var v any
+	if json.Unmarshal(r.Result, &v) != nil {
+		return "result:" + string(r.Result)
+	}
+	b, _ := json.Marshal(v) // map keys come out sorted
+	return "result:" + string(b)
+}
+
+// forwardQuorum reads the call from the first k candidates at once and returns the answer
+// given by a strict majority of them. Without a majority the client gets a JSON-RPC error.
+// Upstreams that failed, and upstreams missing when fewer than k are available, count as
+// missing votes: one healthy node can't make a quorum of 3.
+func (p *Proxy) forwardQuorum(ctx context.Context, up upstreamRequest, candidates []registry.NodeWithPing, start time.Time, k int) *proxyResult {
+	n := min(k, len(candidates))
+	votes := make(chan quorumVote, n)
+	for i, node := range candidates[:n] {
+		go func() {
+			res := p.attempt(ctx, up, node, i, start)
+			if res != nil && res.retryable {
+				res = nil // the node couldn't answer: a missing vote
+			}
+			v := quorumVote{res: res, upstream: node.URL}
+			if res != nil {
+				v.answer = quorumAnswer(res.body)
+			}
+			votes <- v
+		}()
+	}
+
+	groups := map[string][]quorumVote{}
+	var order []string
+	for range n {
+		v := <-votes
+		if v.res == nil {
+			continue
+		}
+		if _, ok := groups[v.answer]; !ok {
+			order = append(order, v.answer)
+		}
+		groups[v.answer] = append(groups[v.answer], v)
+	}
+	if len(order) == 0 {
+		return nil
+	}
+
+	best := order[0]
+	for _, a := range order[1:] {
+		if len(groups[a]) > len(groups[best]) {
+			best = a
+		}
+	}
+	agreed := len(groups[best])
+	tally := fmt.Sprintf("%d/%d", agreed, k)
+	call, _ := parseRPCCall(up.body)
+
+	if len(order) > 1 {
+		var majority, dissent []string
+		for _, a := range order {
+			for _, v := range groups[a] {
+				if a == best {
+					majority = append(majority, secrets.RedactString(v.upstream))
+				} else {
+					dissent = append(dissent, secrets.RedactString(v.upstream))
+				}
+			}
+		}
+		p.Logger.Warn("proxy_quorum_mismatch",
+			zap.String("network", up.network),
+			zap.String("method", call.Method),
+			zap.String("agreed", tally),
+			zap.Strings("majority", majority),
+			zap.Strings("dissent", dissent),
+		)
+	}
+
+	if agreed < k/2+1 {
+		metrics.ProxyQuorumReads.WithLabelValues(up.network, "no_quorum").Inc()
+		hdr := http.Header{}
+		hdr.Set("content-type", "application/json")
+		hdr.Set(quorumHeader, tally)
+		return &proxyResult{
+			status: http.StatusOK,
+			header: hdr,
+			body:   rpcErrorResponse(call.ID, rpcCodeNoQuorum, "no quorum: upstreams disagree ("+tally+")"),
+		}
+	}
+
+	if len(order) > 1 {
+		metrics.ProxyQuorumReads.WithLabelValues(up.network, "majority").Inc()
+	} else {
+		metrics.ProxyQuorumReads.WithLabelValues(up.network, "agreed").Inc()
+	}
+	res := *groups[best][0].res
+	res.header = res.header.Clone()
+	res.header.Set(quorumHeader, tally)
+	return &res
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+func TestServe_QuorumReads(t *testing.T) {
+	var hits atomic.Int32
+	answer := func(result *atomic.Value) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			hits.Add(1)
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + result.Load().(string) + `}`))
+		}))
+	}
+	results := make([]*atomic.Value, 3)
+	nodes := make([]registry.NodeWithPing, 3)
+	for i := range results {
+		results[i] = &atomic.Value{}
+		results[i].Store(`{"a":1,"b":2}`)
+		srv := answer(results[i])
+		defer srv.Close()
+		nodes[i] = registry.NodeWithPing{Node: networks.Node{URL: srv.URL, Priority: i + 1}, Alive: true}
+	}
+	results[2].Store(`{"b":2,"a":1}`) // same answer, different key order
+
+	reg := registry.New()
+	reg.AddNetwork(networks.NetworkConfig{
+		Route:    "/eth",
+		Protocol: "evm",
+		Quorum:   networks.QuorumConfig{Methods: []string{"eth_getBalance"}},
+	}, nodes)
+	p := NewProxy(reg, zap.NewNop(), "")
+
+	call := func(method, header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/eth", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"`+method+`","params":[]}`))
+		if header != "" {
+			req.Header.Set(quorumHeader, header)
+		}
+		rec := httptest.NewRecorder()
+		p.Serve(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec
+	}
+
+	rec := call("eth_getBalance", "")
+	require.Equal(t, "3/3", rec.Header().Get(quorumHeader))
+	require.EqualValues(t, 3, hits.Load())
+
+	results[1].Store(`"stale"`)
+	rec = call("eth_call", "3")
+	require.Equal(t, "2/3", rec.Header().Get(quorumHeader))
+	require.Contains(t, rec.Body.String(), `"a":1`)
+
+	results[0].Store(`"other"`)
+	rec = call("eth_getBalance", "")
+	require.Equal(t, "1/3", rec.Header().Get(quorumHeader))
+	var reply rpcErrorReply
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reply))
+	require.Equal(t, rpcCodeNoQuorum, reply.Error.Code)
+
+	hits.Store(0)
+	rec = call("eth_getBalance", "1")
+	require.Empty(t, rec.Header().Get(quorumHeader))
+	require.EqualValues(t, 1, hits.Load(), "a quorum of 1 is a plain read")
+
+	// sizes are capped, and missing upstreams count as missing votes
+	for _, r := range results {
+		r.Store(`"same"`)
+	}
+	rec = call("eth_getBalance", "100")
+	require.Equal(t, "3/5", rec.Header().Get(quorumHeader))
+	require.Contains(t, rec.Body.String(), `"same"`)
+
+	reg.AddNetwork(networks.NetworkConfig{
+		Route:    "/bsc",
+		Protocol: "evm",
+		Quorum:   networks.QuorumConfig{Methods: []string{"eth_getBalance"}},
+	}, nodes[:1])
+	req := httptest.NewRequest(http.MethodPost, "/bsc", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_getBalance","params":[]}`))
+	rec = httptest.NewRecorder()
+	p.Serve(rec, req)
+	require.Equal(t, "1/3", rec.Header().Get(quorumHeader))
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reply))
+	require.Equal(t, rpcCodeNoQuorum, reply.Error.Code, "one node is no quorum")
+}
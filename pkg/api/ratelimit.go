@@ -1,9 +1,12 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func isRateLimited(resp *http.Response, body []byte) bool {
@@ -39,3 +42,158 @@ func looksLikeRL(s string) bool {
 		strings.Contains(s, "too many request") ||
 		strings.Contains(s, "too many requests")
 }
+
+// retryAfter reads how long a provider asks us to back off from Retry-After
+// (seconds or HTTP date) or X-RateLimit-Reset (seconds or unix time). Zero means no hint.
+func retryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	now := time.Now()
+	if v := strings.TrimSpace(resp.Header.Get("Retry-After")); v != "" {
+		if secs, err := strconv.ParseFloat(v, 64); err == nil && secs > 0 {
+			return time.Duration(secs * float64(time.Second))
+		}
+		if t, err := http.ParseTime(v); err == nil && t.After(now) {
+			return t.Sub(now)
+		}
+	}
+	if v := strings.TrimSpace(resp.Header.Get("X-RateLimit-Reset")); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			// values this large are a reset timestamp, not a delay
+			if n > 1_000_000_000 {
+				if t := time.Unix(n, 0); t.After(now) {
+					return t.Sub(now)
+				}
+				return 0
+			}
+			return time.Duration(n) * time.Second
+		}
+	}
+	return 0
+}
+
+// rpcErrorClass tells the proxy what to do with a JSON-RPC error reply.
+type rpcErrorClass int
+
+const (
+	rpcErrNone       rpcErrorClass = iota // not an error reply
+	rpcErrClient                          // caused by the request itself: return it as is
+	rpcErrRetryable                       // this node can't answer right now: try another one
+	rpcErrNodeBroken                      // the node is unhealthy: try another one and count a failure
+)
+
+func (c rpcErrorClass) String() string {
+	switch c {
+	case rpcErrClient:
+		return "client"
+	case rpcErrRetryable:
+		return "retryable"
+	case rpcErrNodeBroken:
+		return "node_broken"
+	default:
+		return "none"
+	}
+}
+
+// rpcErrorRules classify the errors of one protocol by code and message fragment
+// (lower case). Limit codes are checked first, then broken rules.
+type rpcErrorRules struct {
+	// limitCodes are shared by rate limits and limits on the request itself (too many
+	// results, range too wide): retryable only with a rate-limit message
+	limitCodes  map[int]bool
+	brokenCodes map[int]bool
+	brokenText  []string
+	retryCodes  map[int]bool
+	retryText   []string
+}
+
+// rateLimitText are message fragments of provider rate limits.
+var rateLimitText = []string{
+	"rate limit", "rate exceeded", "too many request", "request count exceeded",
+	"requests per second", "compute units", "capacity exceeded",
+}
+
+// commonRPCErrorRules apply to every protocol, mostly provider gateway errors.
+var commonRPCErrorRules = rpcErrorRules{
+	brokenText: []string{
+		"upstream unavailable", "no healthy upstream", "backend unavailable",
+		"service unavailable", "bad gateway", "node is not synced", "node is syncing",
+	},
+	retryText: []string{
+		"timeout", "timed out", "temporarily unavailable", "try again",
+		"limit exceeded", "rate limit", "too many request",
+	},
+}
+
+var utxoRPCErrorRules = rpcErrorRules{
+	brokenCodes: map[int]bool{-28: true}, // RPC_IN_WARMUP: loading block index
+	retryText:   []string{"-txindex"},    // node runs without a tx index
+}
+
+var rpcErrorRulesByProtocol = map[string]rpcErrorRules{
+	"evm": {
+		limitCodes: map[int]bool{-32005: true}, // limit exceeded: rate, or results of eth_getLogs
+		retryText:  []string{"header not found", "missing trie node", "unknown block", "state is not available"},
+	},
+	"sol": {
+		brokenCodes: map[int]bool{-32005: true}, // node is unhealthy / behind
+		retryCodes: map[int]bool{
+			-32004: true, // block not available for slot
+			-32009: true, // slot missing in long-term storage
+			-32014: true, // block status not yet available
+			-32016: true, // minimum context slot not reached
+		},
+	},
+	"btc":  utxoRPCErrorRules,
+	"ltc":  utxoRPCErrorRules,
+	"doge": utxoRPCErrorRules,
+}
+
+func (r rpcErrorRules) broken(e rpcError, msg string) bool {
+	return r.brokenCodes[e.Code] || containsAny(msg, r.brokenText)
+}
+
+func (r rpcErrorRules) retryable(e rpcError, msg string) bool {
+	return r.retryCodes[e.Code] || containsAny(msg, r.retryText)
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyRPCError sorts a JSON-RPC error by the rules of the protocol and the common rules.
+func classifyRPCError(protocol string, e rpcError) rpcErrorClass {
+	msg := strings.ToLower(e.Message)
+	rules := rpcErrorRulesByProtocol[strings.ToLower(protocol)]
+	switch {
+	case rules.limitCodes[e.Code] && containsAny(msg, rateLimitText):
+		return rpcErrRetryable
+	case rules.limitCodes[e.Code]:
+		return rpcErrClient
+	case rules.broken(e, msg) || commonRPCErrorRules.broken(e, msg):
+		return rpcErrNodeBroken
+	case rules.retryable(e, msg) || commonRPCErrorRules.retryable(e, msg):
+		return rpcErrRetryable
+	}
+	return rpcErrClient
+}
+
+// classifyRPCReply classifies the error of a single JSON-RPC reply; batches and
+// non-JSON-RPC bodies are rpcErrNone.
+func classifyRPCReply(protocol string, body []byte) rpcErrorClass {
+	var r struct {
+		Error *rpcError `json:"error"`
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '{' || !bytes.Contains(body, []byte(`"error"`)) ||
+		json.Unmarshal(body, &r) != nil || r.Error == nil {
+		return rpcErrNone
+	}
+	return classifyRPCError(protocol, *r.Error)
+}
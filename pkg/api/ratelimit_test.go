@@ -0,0 +1,122 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+func TestRetryAfter(t *testing.T) {
+	resp := func(k, v string) *http.Response {
+		return &http.Response{Header: http.Header{k: []string{v}}}
+	}
+	require.Equal(t, 7*time.Second, retryAfter(resp("Retry-After", "7")))
+	require.InDelta(t, float64(30*time.Second), float64(retryAfter(resp("Retry-After", time.Now().Add(30*time.Second).UTC().Format(http.TimeFormat)))), float64(2*time.Second))
+	require.Equal(t, 12*time.Second, retryAfter(resp("X-Ratelimit-Reset", "12")))
+	reset := strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)
+	require.InDelta(t, float64(time.Minute), float64(retryAfter(resp("X-Ratelimit-Reset", reset))), float64(2*time.Second))
+	require.Zero(t, retryAfter(resp("Retry-After", "soon")))
+	require.Zero(t, retryAfter(&http.Response{Header: http.Header{}}))
+}
+
+func TestServe_CoolsDownThrottledNode(t *testing.T) {
+	var throttledHits atomic.Int32
+	throttled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		throttledHits.Add(1)
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer throttled.Close()
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"ok"}`))
+	}))
+	defer good.Close()
+
+	reg := registry.New()
+	reg.AddNetwork(networks.NetworkConfig{Route: "/eth", Protocol: "evm", Strategy: networks.StrategyPriorityFailover}, []registry.NodeWithPing{
+		{Node: networks.Node{URL: throttled.URL, Priority: 1}, Alive: true},
+		{Node: networks.Node{URL: good.URL, Priority: 2}, Alive: true},
+	})
+	p := NewProxy(reg, zap.NewNop(), "")
+
+	call := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		p.Serve(rec, httptest.NewRequest(http.MethodPost, "/eth", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_call","params":[]}`)))
+		return rec
+	}
+	for i := 0; i < 3; i++ {
+		require.Equal(t, http.StatusOK, call().Code)
+	}
+	require.EqualValues(t, 1, throttledHits.Load(), "throttled node must sit out its Retry-After window")
+	require.True(t, reg.CoolingDown(throttled.URL))
+
+	reg.Stats(good.URL).CoolDown(time.Minute)
+	rec := call()
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.NotEmpty(t, rec.Header().Get("Retry-After"))
+}
+
+func TestClassifyRPCError(t *testing.T) {
+	cases := []struct {
+		protocol string
+		err      rpcError
+		class    rpcErrorClass
+	}{
+		{"evm", rpcError{Code: -32000, Message: "header not found"}, rpcErrRetryable},
+		{"evm", rpcError{Code: -32000, Message: "missing trie node 1a2b (path )"}, rpcErrRetryable},
+		{"evm", rpcError{Code: -32005, Message: "query returned more than 10000 results"}, rpcErrClient},
+		{"evm", rpcError{Code: -32005, Message: "query limit exceeded"}, rpcErrClient},
+		{"evm", rpcError{Code: -32005, Message: "daily request count exceeded, request rate limited"}, rpcErrRetryable},
+		{"evm", rpcError{Code: -32000, Message: "execution reverted"}, rpcErrClient},
+		{"evm", rpcError{Code: -32602, Message: "invalid argument 0: hex string without 0x prefix"}, rpcErrClient},
+		{"evm", rpcError{Code: -32603, Message: "upstream unavailable"}, rpcErrNodeBroken},
+		{"sol", rpcError{Code: -32005, Message: "Node is behind by 42 slots"}, rpcErrNodeBroken},
+		{"sol", rpcError{Code: -32009, Message: "Slot 1 was skipped, or missing in long-term storage"}, rpcErrRetryable},
+		{"btc", rpcError{Code: -28, Message: "Loading block index..."}, rpcErrNodeBroken},
+		{"btc", rpcError{Code: -5, Message: "No such mempool transaction. Use -txindex or provide a block hash"}, rpcErrRetryable},
+		{"btc", rpcError{Code: -8, Message: "Block height out of range"}, rpcErrClient},
+		{"trx", rpcError{Code: -32000, Message: "request timed out"}, rpcErrRetryable},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.class, classifyRPCError(c.protocol, c.err), c.err.Message)
+	}
+	require.Equal(t, rpcErrNone, classifyRPCReply("evm", []byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`)))
+	require.Equal(t, rpcErrNone, classifyRPCReply("evm", []byte(`[{"error":{"code":-32000,"message":"header not found"}}]`)))
+}
+
+func TestServe_FailsOverOnNodeSideRPCErrors(t *testing.T) {
+	lagging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"header not found"}}`))
+	}))
+	defer lagging.Close()
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+	defer good.Close()
+
+	serve := func(nodes ...string) string {
+		reg := registry.New()
+		list := make([]registry.NodeWithPing, 0, len(nodes))
+		for i, u := range nodes {
+			list = append(list, registry.NodeWithPing{Node: networks.Node{URL: u, Priority: i + 1}, Alive: true})
+		}
+		reg.AddNetwork(networks.NetworkConfig{Route: "/eth", Protocol: "evm"}, list)
+		rec := httptest.NewRecorder()
+		NewProxy(reg, zap.NewNop(), "").Serve(rec, httptest.NewRequest(http.MethodPost, "/eth",
+			strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["0x10",false]}`)))
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec.Body.String()
+	}
+	require.Contains(t, serve(lagging.URL, good.URL), `"0x1"`)
+	require.Contains(t, serve(lagging.URL), "header not found", "the node's error is returned when no other node answers")
+}
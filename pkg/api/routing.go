@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+// requiredTags returns the node tags a call needs according to the network's routing
+// rules; nil means any node will do.
+func (p *Proxy) requiredTags(network string, call rpcCall) []string {
+	for _, rule := range p.Reg.Routing(network) {
+		if !networks.MatchMethod(rule.Methods, call.Method) {
+			continue
+		}
+		if rule.MinBlockRange > 0 && call.Method == "eth_getLogs" && !p.wideLogRange(network, call, rule.MinBlockRange) {
+			continue
+		}
+		return rule.Tags
+	}
+	return nil
+}
+
+// wideLogRange reports whether an eth_getLogs filter spans at least minRange blocks.
+// Block tags resolve against the latest known head; while the head is unknown only
+// ranges starting at genesis count as wide.
+func (p *Proxy) wideLogRange(network string, call rpcCall, minRange uint64) bool {
+	var params []struct {
+		FromBlock json.RawMessage `json:"fromBlock"`
+		ToBlock   json.RawMessage `json:"toBlock"`
+		BlockHash string          `json:"blockHash"`
+	}
+	if json.Unmarshal(call.Params, &params) != nil || len(params) == 0 {
+		return false
+	}
+	f := params[0]
+	if f.BlockHash != "" {
+		return false // a single block
+	}
+	head, headKnown := p.Cache.head(network)
+	resolve := func(v json.RawMessage) (uint64, bool) {
+		if n, ok := parseHexQuantity(v); ok {
+			return n, true
+		}
+		var tag string
+		_ = json.Unmarshal(v, &tag)
+		if tag == "earliest" {
+			return 0, true
+		}
+		// absent, "latest", "safe", "finalized", "pending"
+		return head, headKnown
+	}
+	from, fromOK := resolve(f.FromBlock)
+	to, toOK := resolve(f.ToBlock)
+	if !fromOK || !toOK {
+		return fromOK && from == 0
+	}
+	return to >= from && to-from >= minRange
+}
+
+// withTags keeps the candidates that carry every tag in tags.
+func withTags(candidates []registry.NodeWithPing, tags []string) []registry.NodeWithPing {
+	if len(tags) == 0 {
+		return candidates
+	}
+	out := make([]registry.NodeWithPing, 0, len(candidates))
+	for _, n := range candidates {
+		if hasTags(n.Tags, tags) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func hasTags(have, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if strings.EqualFold(h, w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
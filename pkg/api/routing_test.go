@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+)
+
+// namedRPC answers single calls and batches with its own name as result.
+func namedRPC(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		reply := func(id json.RawMessage) map[string]any {
+			return map[string]any{"jsonrpc": "2.0", "id": id, "result": name}
+		}
+		if isJSONArray(body) {
+			var calls []rpcCall
+			_ = json.Unmarshal(body, &calls)
+			out := make([]map[string]any, 0, len(calls))
+			for _, c := range calls {
+				out = append(out, reply(c.ID))
+			}
+			_ = json.NewEncoder(w).Encode(out)
+			return
+		}
+		c, _ := parseRPCCall(body)
+		_ = json.NewEncoder(w).Encode(reply(c.ID))
+	}))
+}
+
+func TestServe_RoutesByTags(t *testing.T) {
+	public := namedRPC("public")
+	defer public.Close()
+	archive := namedRPC("archive")
+	defer archive.Close()
+
+	reg := registry.New()
+	reg.AddNetwork(networks.NetworkConfig{
+		Route:    "/eth",
+		Protocol: "evm",
+		Strategy: networks.StrategyPriorityFailover,
+		Routing: []networks.MethodRoute{
+			{Methods: []string{"debug_*", "trace_*"}, Tags: []string{"trace"}},
+			{Methods: []string{"eth_getLogs"}, Tags: []string{"archive"}, MinBlockRange: 1000},
+		},
+	}, []registry.NodeWithPing{
+		{Node: networks.Node{URL: public.URL, Priority: 1}, Alive: true},
+		{Node: networks.Node{URL: archive.URL, Priority: 2, Tags: []string{"archive", "Trace"}}, Alive: true},
+	})
+	p := NewProxy(reg, zap.NewNop(), "")
+
+	call := func(body string) string {
+		rec := httptest.NewRecorder()
+		p.Serve(rec, httptest.NewRequest(http.MethodPost, "/eth", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec.Body.String()
+	}
+	require.Contains(t, call(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}`), `"public"`)
+	require.Contains(t, call(`{"jsonrpc":"2.0","id":1,"method":"trace_block","params":["0x1"]}`), `"archive"`)
+	require.Contains(t, call(`{"jsonrpc":"2.0","id":1,"method":"eth_getLogs","params":[{"fromBlock":"0x10","toBlock":"0x20"}]}`), `"public"`)
+	require.Contains(t, call(`{"jsonrpc":"2.0","id":1,"method":"eth_getLogs","params":[{"fromBlock":"earliest"}]}`), `"archive"`)
+
+	var out []struct {
+		Result string `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(call(`[
+		{"jsonrpc":"2.0","id":1,"method":"eth_chainId"},
+		{"jsonrpc":"2.0","id":2,"method":"debug_traceTransaction","params":["0xabc"]}
+	]`)), &out))
+	require.Equal(t, "public", out[0].Result)
+	require.Equal(t, "archive", out[1].Result)
+}
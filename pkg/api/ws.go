@@ -3,6 +3,7 @@ package api
 import (
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/gorilla/websocket"
 	"github.com/shuliakovsky/rpc-forwarder/pkg/metrics"
@@ -30,7 +31,15 @@ func (w *WS) ServeWS(rw http.ResponseWriter, r *http.Request) {
 		http.Error(rw, "bad path", http.StatusBadRequest)
 		return
 	}
-	network := parts[0]
+	network, ok := w.Reg.Resolve(parts[0])
+	if !ok {
+		http.NotFound(rw, r)
+		return
+	}
+	if w.Reg.Disabled(network) {
+		http.Error(rw, "network "+network+" is disabled", http.StatusServiceUnavailable)
+		return
+	}
 	nodes := w.Reg.Best(network)
 	if len(nodes) == 0 {
 		http.Error(rw, "no healthy nodes", http.StatusServiceUnavailable)
@@ -61,6 +70,14 @@ func (w *WS) ServeWS(rw http.ResponseWriter, r *http.Request) {
 	w.Logger.Info("ws_proxy_connected", zap.String("network", network), zap.String("upstream", upstream))
 	metrics.WSConnected.WithLabelValues(network).Inc()
 
+	// gorilla/websocket allows a single writer per connection
+	var clientMu sync.Mutex
+	writeClient := func(mt int, msg []byte) error {
+		clientMu.Lock()
+		defer clientMu.Unlock()
+		return clientConn.WriteMessage(mt, msg)
+	}
+
 	// bidirectional copy
 	go func() {
 		for {
@@ -77,6 +94,14 @@ func (w *WS) ServeWS(rw http.ResponseWriter, r *http.Request) {
 				if strings.Contains(string(msg), "eth_unsubscribe") {
 					w.Logger.Info("ws_unsubscribe", zap.String("network", network), zap.String("payload", secrets.RedactString(string(msg))))
 				}
+				if reply := w.rejectBlocked(network, msg); reply != nil {
+					if err := writeClient(websocket.TextMessage, reply); err != nil {
+						w.Logger.Warn("ws_client_write_error", zap.Error(err))
+						metrics.WSError.WithLabelValues(network).Inc()
+						return
+					}
+					continue
+				}
 			}
 			if err := upstreamConn.WriteMessage(mt, msg); err != nil {
 				w.Logger.Warn("ws_upstream_write_error", zap.Error(err))
@@ -93,7 +118,7 @@ func (w *WS) ServeWS(rw http.ResponseWriter, r *http.Request) {
 			metrics.WSError.WithLabelValues(network).Inc()
 			return
 		}
-		if err := clientConn.WriteMessage(mt, msg); err != nil {
+		if err := writeClient(mt, msg); err != nil {
 			w.Logger.Warn("ws_client_write_error", zap.Error(err))
 			metrics.WSError.WithLabelValues(network).Inc()
 			return
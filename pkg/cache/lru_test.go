@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU(2)
+	c.Set("a", []byte("1"), 0)
+	c.Set("b", []byte("2"), 0)
+	_, _ = c.Get("a")
+	c.Set("c", []byte("3"), 0)
+
+	_, ok := c.Get("b")
+	require.False(t, ok, "b should be evicted")
+	v, ok := c.Get("a")
+	require.True(t, ok)
+	require.Equal(t, "1", string(v))
+	require.Equal(t, 2, c.Len())
+}
+
+func TestLRU_Expires(t *testing.T) {
+	c := NewLRU(10)
+	c.Set("k", []byte("v"), 10*time.Millisecond)
+	_, ok := c.Get("k")
+	require.True(t, ok)
+	time.Sleep(20 * time.Millisecond)
+	_, ok = c.Get("k")
+	require.False(t, ok)
+}
@@ -3,14 +3,18 @@ package health
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/shuliakovsky/rpc-forwarder/pkg/metrics"
 	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/protocols"
 	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
 	"github.com/shuliakovsky/rpc-forwarder/pkg/secrets"
 	"go.uber.org/zap"
@@ -21,8 +25,13 @@ type Checker struct {
 	TorSocks5 string
 	Logger    *zap.Logger
 	Reg       *registry.Registry
-	dropMu    sync.Mutex
-	dropURLs  map[string]struct{}
+
+	idMu     sync.Mutex
+	verified map[string]time.Time // network|url|identity → last successful check
+	rejected map[string]struct{}  // network|url|identity of upstreams serving another chain
+
+	syncMu sync.Mutex
+	synced map[string]time.Time // url → last sync-status check that found the node in sync
 }
 
 func New(tor string, logger *zap.Logger, reg *registry.Registry) *Checker {
@@ -33,7 +42,9 @@ func New(tor string, logger *zap.Logger, reg *registry.Registry) *Checker {
 		TorSocks5: tor,
 		Logger:    logger,
 		Reg:       reg,
-		dropURLs:  map[string]struct{}{},
+		verified:  map[string]time.Time{},
+		rejected:  map[string]struct{}{},
+		synced:    map[string]time.Time{},
 	}
 }
 
@@ -55,53 +66,91 @@ func (c *Checker) httpClient(tor bool, timeout time.Duration) (*http.Client, err
 	return &http.Client{Transport: transport, Timeout: timeout}, nil
 }
 
-func (c *Checker) perNodeTimeout(protocol string) time.Duration {
-	tmo := time.Duration(c.Reg.TimeoutMs(protocol)) * time.Millisecond
+func (c *Checker) perNodeTimeout(network, protocol string) time.Duration {
+	tmo := time.Duration(c.Reg.TimeoutMs(network)) * time.Millisecond
 	if tmo <= 0 {
-		tmo = defaultTimeoutFor(protocol)
+		tmo = protocols.Timeout(protocol)
 	}
 	return tmo
 }
 
-func defaultTimeoutFor(network string) time.Duration {
-	switch strings.ToLower(network) {
-	case "sol":
-		return 800 * time.Millisecond
-	case "eth", "evm", "bsc", "polygon", "fantom":
-		return 1500 * time.Millisecond
-	case "trx":
-		return 1500 * time.Millisecond
-	case "btc", "doge", "ltc":
-		return 2000 * time.Millisecond
-	default:
-		return 1500 * time.Millisecond
-	}
-}
-
 // === UpdateNetwork ===
-func (c *Checker) UpdateNetwork(protocol string, nodes []networks.Node) []registry.NodeWithPing {
+func (c *Checker) UpdateNetwork(network, protocol string, nodes []networks.Node) []registry.NodeWithPing {
 	res := make([]registry.NodeWithPing, 0, len(nodes))
 	// get timeout for this network
-	tmo := c.perNodeTimeout(protocol)
+	tmo := c.perNodeTimeout(network, protocol)
+	proto, known := protocols.Lookup(protocol)
+	want := c.Reg.ChainIdentity(network)
+	status := c.Reg.NodeStatus(network)
 
 	for _, n := range nodes {
-		var alive bool
+		// Throttled providers are not probed: they answered, they just asked us to back off.
+		// They keep their last known status; a node that was never checked stays out of
+		// rotation until a probe (and the chain identity check) has passed.
+		if until, ok := c.Reg.Stats(n.URL).CooldownUntil(); ok {
+			c.Logger.Debug("health_node_cooling_down",
+				safeURLField(n.URL),
+				zap.String("protocol", protocol),
+				zap.Time("until", until),
+			)
+			prev, seen := status[n.URL]
+			if !seen {
+				prev = registry.NodeWithPing{Reason: "cooling down, not checked yet"}
+			}
+			prev.Node = n
+			if latency, ok := c.Reg.Stats(n.URL).LatencyMs(); ok && prev.Alive {
+				prev.Ping = int64(latency)
+			}
+			res = append(res, prev)
+			continue
+		}
+		// Quarantined nodes wait for their next re-probe.
+		if until, ok := c.Reg.Stats(n.URL).QuarantinedUntil(); ok && time.Now().Before(until) {
+			reason := "quarantined"
+			if prev, ok := status[n.URL]; ok && prev.Reason != "" {
+				reason = prev.Reason
+			}
+			res = append(res, registry.NodeWithPing{Node: n, Reason: reason})
+			continue
+		}
+		// No traffic to a node before it proved to serve the right chain.
+		if want != "" {
+			switch c.verifyChain(network, protocol, want, n, tmo) {
+			case chainMismatch:
+				continue
+			case chainUnverified:
+				res = append(res, registry.NodeWithPing{Node: n, Reason: "chain identity not verified"})
+				continue
+			}
+		}
 		var ping int64
-		switch protocol {
-		case "evm":
-			alive, ping = c.checkEVM(n, tmo)
-		case "btc":
-			alive, ping = c.checkBTC(n, tmo)
-		case "trx":
-			alive, ping = c.checkTRX(n, tmo)
-		case "ltc":
-			alive, ping = c.checkLTC(n, tmo)
-		case "doge":
-			alive, ping = c.checkDOGE(n, tmo)
-		case "sol":
-			alive, ping = c.checkSOL(n, tmo)
-		default:
-			alive, ping = false, 0
+		var height uint64
+		var reason string
+		if !known {
+			reason = "unknown protocol " + protocol
+		} else if p, h, err := c.probe(proto, n, tmo); err != nil {
+			c.Reg.Stats(n.URL).Observe(registry.OutcomeFailed)
+			reason = "probe failed: " + secrets.RedactString(err.Error())
+			// fatal errors start a quarantine, any failure extends it
+			var fe fatalError
+			if _, q := c.Reg.Stats(n.URL).QuarantinedUntil(); q || errors.As(err, &fe) {
+				reason = "quarantined: " + reason
+				c.quarantine(network, n, reason)
+			}
+		} else {
+			c.Reg.Stats(n.URL).Observe(registry.OutcomeOK)
+			if c.Reg.Stats(n.URL).Release() {
+				c.Logger.Info("health_node_restored",
+					safeURLField(n.URL),
+					zap.String("network", network),
+				)
+			}
+			ping, height = p, h
+			reason = c.syncStatus(proto, n, tmo)
+		}
+		alive := reason == ""
+		if height > 0 {
+			c.Reg.Stats(n.URL).SetHead(height)
 		}
 		safeHeaders := secrets.RedactHeaders(n.Headers)
 
@@ -111,6 +160,7 @@ func (c *Checker) UpdateNetwork(protocol string, nodes []networks.Node) []regist
 				zap.String("protocol", protocol),
 				zap.Int("priority", n.Priority),
 				zap.Any("headers", safeHeaders),
+				zap.String("reason", reason),
 			)
 		} else {
 			c.Logger.Debug("health_node_alive",
@@ -121,9 +171,102 @@ func (c *Checker) UpdateNetwork(protocol string, nodes []networks.Node) []regist
 				zap.Any("headers", safeHeaders),
 			)
 		}
-		res = append(res, registry.NodeWithPing{Node: n, Alive: alive, Ping: ping})
+		res = append(res, registry.NodeWithPing{Node: n, Alive: alive, Ping: ping, Height: height, Reason: reason})
 	}
-	return registry.PickFastestPerPriority(res)
+	c.markLagging(network, protocol, res)
+	c.Reg.SetStatus(network, res)
+	return registry.PickHealthyTiers(res)
+}
+
+// markLagging computes the network head from the probed heights and takes nodes
+// more than maxLagBlocks behind it out of rotation. A check of a single node (admin
+// add) is compared against the head of the last full round instead.
+func (c *Checker) markLagging(network, protocol string, res []registry.NodeWithPing) {
+	limit := c.Reg.MaxLagBlocks(network)
+	maxLag := protocols.MaxLag(protocol)
+	if limit > 0 {
+		maxLag = uint64(limit)
+	}
+
+	prev := c.Reg.Head(network)
+	var head uint64
+	if len(res) > 1 {
+		heights := make([]uint64, 0, len(res))
+		for _, n := range res {
+			if n.Alive && n.Height > 0 {
+				heights = append(heights, n.Height)
+			}
+		}
+		head = networkHead(heights, prev, maxLag)
+		if head > 0 {
+			c.Reg.SetHead(network, head)
+		}
+	} else {
+		for _, n := range res {
+			head = n.Height
+		}
+		head = max(head, prev)
+	}
+	if head == 0 {
+		return
+	}
+	for i := range res {
+		n := &res[i]
+		if n.Height == 0 {
+			continue // no height from this probe (or not probed): nothing to compare
+		}
+		n.Lag = 0
+		if n.Height < head {
+			n.Lag = head - n.Height
+		}
+		metrics.NodeLag.WithLabelValues(network, secrets.RedactString(n.URL)).Set(float64(n.Lag))
+		if limit < 0 || n.Lag <= maxLag || !n.Alive {
+			continue
+		}
+		n.Alive = false
+		n.Reason = fmt.Sprintf("lagging %d blocks behind head", n.Lag)
+		c.Logger.Warn("health_node_lagging",
+			safeURLField(n.URL),
+			zap.String("network", network),
+			zap.Uint64("height", n.Height),
+			zap.Uint64("head", head),
+			zap.Uint64("lag", n.Lag),
+		)
+	}
+}
+
+// headJumpFactor bounds how far past the previous head (in multiples of maxLag) a height
+// may be in one round before it is disregarded as bogus.
+const headJumpFactor = 20
+
+// networkHead picks the head from the heights of one round so that a single node
+// reporting a bogus height can't mark every honest node as lagging: it is the highest
+// height confirmed by another node within maxLag, or the median if no two nodes agree.
+// Heights implausibly far past the previous head are ignored while others remain.
+func networkHead(heights []uint64, prev, maxLag uint64) uint64 {
+	if prev > 0 {
+		plausible := make([]uint64, 0, len(heights))
+		for _, h := range heights {
+			if h <= prev+headJumpFactor*max(maxLag, 1) {
+				plausible = append(plausible, h)
+			}
+		}
+		if len(plausible) > 0 {
+			heights = plausible
+		}
+	}
+	if len(heights) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(heights)
+	slices.Sort(sorted)
+	slices.Reverse(sorted)
+	for i := 0; i+1 < len(sorted); i++ {
+		if sorted[i]-sorted[i+1] <= maxLag {
+			return sorted[i]
+		}
+	}
+	return sorted[len(sorted)/2]
 }
 
 func safeURLField(url string) zap.Field {
@@ -171,21 +314,3 @@ func isFatalNetErr(err error) bool {
 	}
 	return false
 }
-
-func (c *Checker) markDrop(url string) {
-	c.dropMu.Lock()
-	c.dropURLs[url] = struct{}{}
-	c.dropMu.Unlock()
-}
-
-// DrainDropURLs returns and clears accumulated URLs to drop.
-func (c *Checker) DrainDropURLs() []string {
-	c.dropMu.Lock()
-	defer c.dropMu.Unlock()
-	out := make([]string, 0, len(c.dropURLs))
-	for u := range c.dropURLs {
-		out = append(out, u)
-	}
-	c.dropURLs = map[string]struct{}{}
-	return out
-}
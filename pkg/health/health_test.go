@@ -6,11 +6,13 @@ import (
 	"net/http/httptest"
 	"os"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/protocols"
 	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
@@ -27,6 +29,12 @@ func newTestChecker() *Checker {
 	return New("", logger, reg)
 }
 
+func mustProtocol(t *testing.T, name string) protocols.Protocol {
+	p, ok := protocols.Lookup(name)
+	require.True(t, ok, name)
+	return p
+}
+
 func TestCheckEVM_OK(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -35,8 +43,8 @@ func TestCheckEVM_OK(t *testing.T) {
 	defer srv.Close()
 
 	h := newTestChecker()
-	ok, ping := h.checkEVM(networks.Node{URL: srv.URL}, 2*time.Second)
-	require.True(t, ok, "EVM node should be alive")
+	ping, _, err := h.probe(mustProtocol(t, "evm"), networks.Node{URL: srv.URL}, 2*time.Second)
+	require.NoError(t, err, "EVM node should be alive")
 	require.GreaterOrEqual(t, ping, int64(0), "ping should be non-negative")
 }
 
@@ -51,8 +59,8 @@ func TestCheckBTC_AllVariants(t *testing.T) {
 		}))
 		defer srv.Close()
 
-		ok, _ := h.checkBTC(networks.Node{URL: srv.URL + "/api"}, 2*time.Second)
-		require.True(t, ok)
+		_, _, err := h.probe(mustProtocol(t, "btc"), networks.Node{URL: srv.URL + "/api"}, 2*time.Second)
+		require.NoError(t, err)
 	})
 
 	t.Run("Tatum gateway variant", func(t *testing.T) {
@@ -73,11 +81,11 @@ func TestCheckBTC_AllVariants(t *testing.T) {
 
 		tatumURL := strings.Replace(srv.URL, "127.0.0.1", "gateway.tatum.io", 1)
 
-		ok, _ := h.checkBTC(networks.Node{
+		_, _, err := h.probe(mustProtocol(t, "btc"), networks.Node{
 			URL:     tatumURL,
 			Headers: map[string]string{"x-api-key": apiKey},
 		}, 2*time.Second)
-		require.True(t, ok, "Tatum gateway node should be alive")
+		require.NoError(t, err, "Tatum gateway node should be alive")
 	})
 }
 
@@ -101,7 +109,223 @@ func TestUpdateNetwork_Prioritizes(t *testing.T) {
 	}
 
 	h := newTestChecker()
-	res := h.UpdateNetwork("evm", nodes)
+	res := h.UpdateNetwork("eth", "evm", nodes)
 	require.Equal(t, 2, len(res))
 	require.Equal(t, nodes[0].URL, res[0].URL, "fast node should be first")
 }
+
+func TestUpdateNetwork_SkipsCoolingDownNodes(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits++
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x10"}`))
+	}))
+	defer srv.Close()
+
+	h := newTestChecker()
+	nodes := []networks.Node{{URL: srv.URL, Priority: 1}}
+	h.Reg.AddNetwork(networks.NetworkConfig{Route: "/eth", Protocol: "evm", Nodes: nodes}, nil)
+	require.Len(t, h.UpdateNetwork("eth", "evm", nodes), 1)
+	probed := hits
+
+	h.Reg.Stats(srv.URL).CoolDown(time.Minute)
+	best := h.UpdateNetwork("eth", "evm", nodes)
+	require.Equal(t, probed, hits, "throttled node must not be probed")
+	require.Len(t, best, 1)
+	require.True(t, best[0].Alive, "keeps its last known status")
+}
+
+func TestUpdateNetwork_CoolingDownKeepsLastStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x10"}`))
+	}))
+	defer srv.Close()
+
+	h := newTestChecker()
+	nodes := []networks.Node{{URL: srv.URL, Priority: 1}}
+	h.Reg.AddNetwork(networks.NetworkConfig{Route: "/eth", Protocol: "evm", ExpectedChainID: "1", Nodes: nodes}, nil)
+
+	// never checked: no traffic before the identity check has run
+	h.Reg.Stats(srv.URL).CoolDown(time.Minute)
+	require.Empty(t, h.UpdateNetwork("eth", "evm", nodes))
+	require.False(t, h.Reg.NodeStatus("eth")[srv.URL].Alive)
+
+	// last seen unhealthy: stays unhealthy while cooling down
+	h.Reg.SetStatus("eth", []registry.NodeWithPing{{Node: nodes[0], Reason: "syncing"}})
+	require.Empty(t, h.UpdateNetwork("eth", "evm", nodes))
+	require.Equal(t, "syncing", h.Reg.NodeStatus("eth")[srv.URL].Reason)
+}
+
+func TestUpdateNetwork_ExcludesLaggingNodes(t *testing.T) {
+	serve := func(height string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"` + height + `"}`))
+		}))
+	}
+	head := serve("0x1000")
+	defer head.Close()
+	near := serve("0xff0")
+	defer near.Close()
+	stale := serve("0xe00")
+	defer stale.Close()
+
+	h := newTestChecker()
+	h.Reg.AddNetwork(networks.NetworkConfig{Route: "/eth", Protocol: "evm", MaxLagBlocks: 20}, nil)
+	best := h.UpdateNetwork("eth", "evm", []networks.Node{
+		{URL: head.URL, Priority: 1},
+		{URL: near.URL, Priority: 1},
+		{URL: stale.URL, Priority: 1},
+	})
+	require.Len(t, best, 2)
+	for _, n := range best {
+		require.NotEqual(t, stale.URL, n.URL, "node 512 blocks behind must be excluded")
+	}
+	require.EqualValues(t, 0x1000, h.Reg.Head("eth"))
+
+	// a single-node check (admin add) is compared with the last full round
+	best = h.UpdateNetwork("eth", "evm", []networks.Node{{URL: stale.URL, Priority: 1}})
+	require.Empty(t, best)
+}
+
+func TestUpdateNetwork_IgnoresBogusHead(t *testing.T) {
+	serve := func(height string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"` + height + `"}`))
+		}))
+	}
+	a := serve("0x1000")
+	defer a.Close()
+	b := serve("0xffa")
+	defer b.Close()
+	bogus := serve("0xffffff")
+	defer bogus.Close()
+
+	h := newTestChecker()
+	h.Reg.AddNetwork(networks.NetworkConfig{Route: "/eth", Protocol: "evm", MaxLagBlocks: 20}, nil)
+	best := h.UpdateNetwork("eth", "evm", []networks.Node{
+		{URL: a.URL, Priority: 1},
+		{URL: b.URL, Priority: 1},
+		{URL: bogus.URL, Priority: 1},
+	})
+	require.Len(t, best, 3, "one node far ahead must not mark the others as lagging")
+	require.EqualValues(t, 0x1000, h.Reg.Head("eth"))
+}
+
+func TestNetworkHead(t *testing.T) {
+	require.EqualValues(t, 100, networkHead([]uint64{100}, 0, 10))
+	require.EqualValues(t, 100, networkHead([]uint64{95, 100, 5000}, 0, 10), "highest height confirmed by two nodes")
+	require.EqualValues(t, 200, networkHead([]uint64{100, 200, 300}, 0, 10), "median when no two nodes agree")
+	require.EqualValues(t, 100, networkHead([]uint64{100, 99_000}, 90, 10), "implausible jumps are ignored")
+	require.EqualValues(t, 99_000, networkHead([]uint64{99_000}, 90, 10), "unless no other height remains")
+}
+
+func TestUpdateNetwork_RejectsWrongChain(t *testing.T) {
+	var wrongProbes atomic.Int32
+	serve := func(chainID string, probes *atomic.Int32) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			if probes != nil {
+				probes.Add(1)
+			}
+			if strings.Contains(string(body), "eth_chainId") {
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"` + chainID + `"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x10"}`))
+		}))
+	}
+	mainnet := serve("0x1", nil)
+	defer mainnet.Close()
+	goerli := serve("0x5", &wrongProbes)
+	defer goerli.Close()
+
+	h := newTestChecker()
+	nodes := []networks.Node{{URL: mainnet.URL, Priority: 1}, {URL: goerli.URL, Priority: 1}}
+	h.Reg.AddNetwork(networks.NetworkConfig{Route: "/eth", Protocol: "evm", ExpectedChainID: "1", Nodes: nodes}, nil)
+
+	best := h.UpdateNetwork("eth", "evm", nodes)
+	require.Len(t, best, 1)
+	require.Equal(t, mainnet.URL, best[0].URL)
+	require.Len(t, h.Reg.All()["eth"].All, 1, "mismatching node must be removed")
+
+	// rejected for good: not even probed again
+	probes := wrongProbes.Load()
+	best = h.UpdateNetwork("eth", "evm", nodes)
+	require.Len(t, best, 1)
+	require.Equal(t, probes, wrongProbes.Load())
+
+	require.Empty(t, h.VerifyChain("eth2", "evm", "1", []networks.Node{{URL: goerli.URL}}))
+}
+
+func TestUpdateNetwork_ExcludesSyncingNodes(t *testing.T) {
+	serve := func(syncing string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			if strings.Contains(string(body), "eth_syncing") {
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + syncing + `}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x10"}`))
+		}))
+	}
+	synced := serve(`false`)
+	defer synced.Close()
+	syncing := serve(`{"startingBlock":"0x0","currentBlock":"0x10","highestBlock":"0x100"}`)
+	defer syncing.Close()
+
+	h := newTestChecker()
+	nodes := []networks.Node{{URL: synced.URL, Priority: 1}, {URL: syncing.URL, Priority: 1}}
+	h.Reg.AddNetwork(networks.NetworkConfig{Route: "/eth", Protocol: "evm", Nodes: nodes}, nil)
+
+	best := h.UpdateNetwork("eth", "evm", nodes)
+	require.Len(t, best, 1)
+	require.Equal(t, synced.URL, best[0].URL)
+
+	st := h.Reg.NodeStatus("eth")[syncing.URL]
+	require.False(t, st.Alive)
+	require.Equal(t, "syncing: block 16 of 256", st.Reason)
+}
+
+func TestUpdateNetwork_QuarantinesFatalNodes(t *testing.T) {
+	var probes atomic.Int32
+	gone := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		probes.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer gone.Close()
+
+	h := newTestChecker()
+	nodes := []networks.Node{{URL: gone.URL, Priority: 1}}
+	h.Reg.AddNetwork(networks.NetworkConfig{Route: "/eth", Protocol: "evm", Nodes: nodes}, nil)
+
+	require.Empty(t, h.UpdateNetwork("eth", "evm", nodes))
+	require.Len(t, h.Reg.All()["eth"].All, 1, "configured nodes are never dropped")
+	_, ok := h.Reg.Stats(gone.URL).QuarantinedUntil()
+	require.True(t, ok)
+	require.Equal(t, "quarantined: probe failed: status 404", h.Reg.NodeStatus("eth")[gone.URL].Reason)
+
+	// not probed again before the retry time
+	seen := probes.Load()
+	require.Empty(t, h.UpdateNetwork("eth", "evm", nodes))
+	require.Equal(t, seen, probes.Load())
+	require.Equal(t, "quarantined: probe failed: status 404", h.Reg.NodeStatus("eth")[gone.URL].Reason)
+}
+
+func TestQuarantine_EvictsDiscoveredNodes(t *testing.T) {
+	h := newTestChecker()
+	configured := networks.Node{URL: "http://configured", Priority: 1}
+	discovered := networks.Node{URL: "http://discovered", Priority: 1}
+	h.Reg.AddNetwork(networks.NetworkConfig{Route: "/eth", Protocol: "evm", Nodes: []networks.Node{configured}}, nil)
+	h.Reg.All()["eth"].Discovered = []registry.DiscoveredNode{{Node: discovered, ExpiresAt: time.Now().Add(time.Minute)}}
+	h.Reg.PruneAndMerge(time.Minute)
+	require.Len(t, h.Reg.All()["eth"].All, 2)
+
+	for range quarantineEvictAfter {
+		h.quarantine("eth", configured, "probe failed")
+		h.quarantine("eth", discovered, "probe failed")
+	}
+	st := h.Reg.All()["eth"]
+	require.Len(t, st.All, 1)
+	require.Equal(t, configured.URL, st.All[0].URL)
+	require.Empty(t, st.Discovered)
+}
@@ -0,0 +1,109 @@
+package health
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/metrics"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/protocols"
+)
+
+// identityRecheck is how often the chain identity of a verified upstream is checked again.
+const identityRecheck = 10 * time.Minute
+
+type chainCheck int
+
+const (
+	chainVerified   chainCheck = iota
+	chainUnverified            // the identity probe failed, try again next round
+	chainMismatch              // the upstream serves another chain: rejected for good
+)
+
+// VerifyChain checks the nodes of a network that isn't registered yet against the chain
+// identity want and returns those verified to serve it. Nodes serving another chain are
+// rejected for good. An empty want lets every node through.
+func (c *Checker) VerifyChain(network, protocol, want string, nodes []networks.Node) []networks.Node {
+	if want == "" {
+		return nodes
+	}
+	tmo := c.perNodeTimeout(network, protocol)
+	out := make([]networks.Node, 0, len(nodes))
+	for _, n := range nodes {
+		if c.verifyChain(network, protocol, want, n, tmo) == chainVerified {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// verifyChain compares the chain identity reported by n with want. A verified node is
+// probed again after identityRecheck; a failed recheck doesn't undo the verification.
+func (c *Checker) verifyChain(network, protocol, want string, n networks.Node, timeout time.Duration) chainCheck {
+	key := network + "|" + n.URL + "|" + want
+	c.idMu.Lock()
+	_, rejected := c.rejected[key]
+	at, verified := c.verified[key]
+	c.idMu.Unlock()
+	if rejected {
+		c.Reg.RemoveNode(network, n.URL) // re-added by gossip or the config
+		return chainMismatch
+	}
+	if verified && time.Since(at) < identityRecheck {
+		return chainVerified
+	}
+
+	got, err := c.probeChain(protocol, n, timeout)
+	if err != nil {
+		c.Logger.Warn("health_chain_probe_error",
+			safeURLField(n.URL),
+			zap.String("network", network),
+			zap.String("protocol", protocol),
+			zap.Error(err),
+		)
+		if verified {
+			return chainVerified
+		}
+		return chainUnverified
+	}
+	if got != want {
+		c.idMu.Lock()
+		c.rejected[key] = struct{}{}
+		delete(c.verified, key)
+		c.idMu.Unlock()
+		c.Logger.Error("health_chain_mismatch",
+			safeURLField(n.URL),
+			zap.String("network", network),
+			zap.String("protocol", protocol),
+			zap.String("expected", want),
+			zap.String("got", got),
+		)
+		metrics.ChainMismatches.WithLabelValues(network).Inc()
+		c.Reg.RemoveNode(network, n.URL)
+		return chainMismatch
+	}
+	c.idMu.Lock()
+	c.verified[key] = time.Now()
+	c.idMu.Unlock()
+	return chainVerified
+}
+
+// probeChain asks n for the identity of the chain it serves.
+func (c *Checker) probeChain(protocol string, n networks.Node, timeout time.Duration) (string, error) {
+	p, ok := protocols.Lookup(protocol)
+	if !ok {
+		return "", fmt.Errorf("unknown protocol %q", protocol)
+	}
+	id, ok := p.(protocols.Identifier)
+	if !ok {
+		return "", errors.ErrUnsupported
+	}
+	reply, err := c.send(n, timeout, id.IdentityRequest)
+	if err != nil {
+		return "", err
+	}
+	return id.Identity(reply)
+}
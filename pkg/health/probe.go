@@ -0,0 +1,125 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/protocols"
+)
+
+// maxProbeBody bounds how much of a probe reply is read.
+const maxProbeBody = 1 << 20
+
+// syncRecheck is how often the sync status of an in-sync node is checked again.
+const syncRecheck = 2 * time.Minute
+
+// fatalError is a probe failure that suggests a misconfigured node: unknown host,
+// refused connection, TLS error or a 4xx status.
+type fatalError struct{ error }
+
+// probe runs the protocol's health request against n and returns its ping and the
+// height it reports.
+func (c *Checker) probe(p protocols.Protocol, n networks.Node, timeout time.Duration) (int64, uint64, error) {
+	start := time.Now()
+	reply, err := c.send(n, timeout, p.ProbeRequest)
+	ping := time.Since(start).Milliseconds()
+	if err != nil {
+		return 0, 0, err
+	}
+	height, err := p.Head(reply)
+	if err != nil {
+		return 0, 0, err
+	}
+	return ping, height, nil
+}
+
+// syncStatus runs the protocol's sync-status check on n and returns why n is not in
+// sync, or "". A syncing node is checked every round, an in-sync one every syncRecheck.
+// Failed checks are not held against the node.
+func (c *Checker) syncStatus(p protocols.Protocol, n networks.Node, timeout time.Duration) string {
+	sc, ok := p.(protocols.SyncChecker)
+	if !ok {
+		return ""
+	}
+	c.syncMu.Lock()
+	at, ok := c.synced[n.URL]
+	c.syncMu.Unlock()
+	if ok && time.Since(at) < syncRecheck {
+		return ""
+	}
+
+	reply, err := c.send(n, timeout, sc.SyncRequest)
+	var reason string
+	if err == nil {
+		reason, err = sc.SyncStatus(reply)
+	}
+	if err != nil {
+		if !errors.Is(err, errors.ErrUnsupported) {
+			c.Logger.Debug("health_sync_check_failed",
+				safeURLField(n.URL),
+				zap.String("protocol", p.Name()),
+				zap.Error(err),
+			)
+		}
+		reason = ""
+	}
+
+	c.syncMu.Lock()
+	if reason == "" {
+		c.synced[n.URL] = time.Now()
+	} else {
+		delete(c.synced, n.URL)
+	}
+	c.syncMu.Unlock()
+	if reason != "" {
+		c.Logger.Warn("health_node_syncing",
+			safeURLField(n.URL),
+			zap.String("protocol", p.Name()),
+			zap.String("reason", reason),
+		)
+	}
+	return reason
+}
+
+type requestFunc func(ctx context.Context, n networks.Node) (*http.Request, error)
+
+// send builds a request for n with build, adds the node headers and returns the reply body.
+func (c *Checker) send(n networks.Node, timeout time.Duration, build requestFunc) ([]byte, error) {
+	cl, err := c.httpClient(n.Tor, timeout)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := build(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range n.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := cl.Do(req)
+	if err != nil {
+		if isFatalNetErr(err) {
+			return nil, fatalError{err}
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err := fmt.Errorf("status %d", resp.StatusCode)
+		if isFatalHTTPStatus(resp.StatusCode) {
+			return nil, fatalError{err}
+		}
+		return nil, err
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxProbeBody))
+}
@@ -0,0 +1,37 @@
+package health
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/metrics"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+)
+
+// quarantineEvictAfter is how many failures in a row get a gossip-discovered node evicted.
+const quarantineEvictAfter = 3
+
+// quarantine takes n out of rotation until its next re-probe, with a backoff doubling
+// on every failure in a row. Configured nodes are never dropped; gossip-discovered ones
+// are evicted after quarantineEvictAfter failures and come back only if gossiped again.
+func (c *Checker) quarantine(network string, n networks.Node, reason string) {
+	d, strikes := c.Reg.Stats(n.URL).Quarantine()
+	if strikes >= quarantineEvictAfter && c.Reg.IsDiscovered(network, n.URL) {
+		c.Reg.RemoveNode(network, n.URL)
+		c.Logger.Warn("health_node_evicted",
+			safeURLField(n.URL),
+			zap.String("network", network),
+			zap.Int("failures", strikes),
+			zap.String("reason", reason),
+		)
+		metrics.UpstreamEvictions.WithLabelValues(network).Inc()
+		return
+	}
+	c.Logger.Warn("health_node_quarantined",
+		safeURLField(n.URL),
+		zap.String("network", network),
+		zap.Int("failures", strikes),
+		zap.Duration("retry_in", d),
+		zap.String("reason", reason),
+	)
+	metrics.UpstreamQuarantines.WithLabelValues(network).Inc()
+}
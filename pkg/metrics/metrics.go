@@ -15,6 +15,10 @@ var (
 		prometheus.GaugeOpts{Name: "rpcf_nodes_healthy", Help: "Healthy nodes per network"},
 		[]string{"network"},
 	)
+	NodeLag = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "rpcf_node_lag_blocks", Help: "Blocks (or slots) a node is behind the network head at the last health check"},
+		[]string{"network", "upstream"},
+	)
 	ProxySuccess = prometheus.NewCounterVec(
 		prometheus.CounterOpts{Name: "rpcf_proxy_success_total", Help: "Successful proxy calls"},
 		[]string{"network"},
@@ -23,6 +27,82 @@ var (
 		prometheus.CounterOpts{Name: "rpcf_proxy_fail_total", Help: "Failed proxy calls"},
 		[]string{"network"},
 	)
+	ProxyCoalesced = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "rpcf_proxy_coalesced_total", Help: "Requests served by joining an identical in-flight upstream request"},
+		[]string{"network"},
+	)
+	ProxyHedges = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "rpcf_proxy_hedges_total", Help: "Hedged requests fired at a further upstream"},
+		[]string{"network"},
+	)
+	ProxyHedgeWins = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "rpcf_proxy_hedge_wins_total", Help: "Requests answered by a hedged upstream"},
+		[]string{"network"},
+	)
+	ProxyBatchCalls = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "rpcf_proxy_batch_calls_total", Help: "JSON-RPC calls proxied inside batches"},
+		[]string{"network", "result"},
+	)
+	CircuitState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "rpcf_upstream_circuit_state", Help: "Upstream circuit breaker state: 0 closed, 1 open, 2 half-open"},
+		[]string{"network", "upstream"},
+	)
+	ProxyUpstreamRPCErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "rpcf_proxy_upstream_rpc_errors_total", Help: "Node-side JSON-RPC errors that triggered a failover, by class"},
+		[]string{"network", "class"},
+	)
+	ChainMismatches = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "rpcf_chain_mismatches_total", Help: "Upstreams rejected for serving another chain"},
+		[]string{"network"},
+	)
+	ProxyHeightSkips = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "rpcf_proxy_height_skips_total", Help: "Upstreams skipped for not having the block a request reads"},
+		[]string{"network"},
+	)
+	ProxyQuorumReads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "rpcf_proxy_quorum_reads_total", Help: "Quorum reads by result: agreed, majority, no_quorum"},
+		[]string{"network", "result"},
+	)
+	ProxyBroadcasts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "rpcf_proxy_broadcast_replies_total", Help: "Upstream replies to broadcast transactions by outcome"},
+		[]string{"network", "outcome"},
+	)
+	ProxyBlockedCalls = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "rpcf_proxy_blocked_calls_total", Help: "JSON-RPC calls rejected by the network method policy"},
+		[]string{"network"},
+	)
+	UpstreamCooldowns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "rpcf_upstream_cooldowns_total", Help: "Upstreams put on cooldown after a rate limit"},
+		[]string{"network"},
+	)
+	UpstreamQuarantines = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "rpcf_upstream_quarantines_total", Help: "Upstreams quarantined or kept in quarantine after a failed probe"},
+		[]string{"network"},
+	)
+	UpstreamEvictions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "rpcf_upstream_evictions_total", Help: "Gossip-discovered upstreams evicted after repeated failures"},
+		[]string{"network"},
+	)
+	UpstreamEjections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "rpcf_upstream_ejections_total", Help: "Upstreams ejected as outliers by their passive health score"},
+		[]string{"network"},
+	)
+	ConfigReloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "rpcf_config_reloads_total", Help: "Reloads of configs/networks by result: applied, rejected"},
+		[]string{"result"},
+	)
+	ReplicaOps = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "rpcf_replica_ops_total", Help: "Replicated admin changes by result: published, applied, skipped, rejected"},
+		[]string{"result"},
+	)
+	CacheHits = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "rpcf_cache_hits_total", Help: "JSON-RPC response cache hits"},
+		[]string{"network", "method"},
+	)
+	CacheMisses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "rpcf_cache_misses_total", Help: "JSON-RPC response cache misses"},
+		[]string{"network", "method"},
+	)
 	WSConnected = prometheus.NewCounterVec(
 		prometheus.CounterOpts{Name: "ws_connected_total", Help: "Total WebSocket connections"},
 		[]string{"network"},
@@ -35,7 +115,9 @@ var (
 
 func Init() {
 	prometheus.MustRegister(TotalNodes, HealthyNodes, ProxySuccess, ProxyFail)
-	prometheus.MustRegister(WSConnected, WSError)
+	prometheus.MustRegister(CircuitState, UpstreamCooldowns, UpstreamQuarantines, UpstreamEvictions, UpstreamEjections, NodeLag, ChainMismatches)
+	prometheus.MustRegister(ProxyBatchCalls, ProxyCoalesced, ProxyHedges, ProxyHedgeWins, ProxyUpstreamRPCErrors, ProxyHeightSkips, ProxyQuorumReads, ProxyBroadcasts, ProxyBlockedCalls, CacheHits, CacheMisses)
+	prometheus.MustRegister(WSConnected, WSError, ConfigReloads, ReplicaOps)
 }
 
 func Handler() http.Handler {
@@ -49,6 +49,9 @@ func LoadAll(dir string, logger *zap.Logger) (map[string]NetworkConfig, error) {
 		if nc.Route == "" || nc.Protocol == "" || len(nc.Nodes) == 0 {
 			return nil, fmt.Errorf("%s: invalid network config", e.Name())
 		}
+		if err := nc.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Name(), err)
+		}
 		for i := range nc.Nodes {
 			if nc.Nodes[i].Priority == 0 {
 				nc.Nodes[i].Priority = 1
@@ -1,15 +1,212 @@
 package networks
 
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
 type Node struct {
 	URL      string            `yaml:"url" json:"url"`
 	Priority int               `yaml:"priority" json:"priority"`
 	Headers  map[string]string `yaml:"headers" json:"headers"`
 	Tor      bool              `yaml:"tor" json:"tor"`
+	Weight   int               `yaml:"weight" json:"weight,omitempty"` // weighted-random share, 0 = 1
+	Tags     []string          `yaml:"tags" json:"tags,omitempty"`     // capabilities, e.g. archive, trace
 }
 
 type NetworkConfig struct {
-	Route     string `yaml:"route" json:"route"`
-	Protocol  string `yaml:"protocol" json:"protocol"` // evm|btc
-	Nodes     []Node `yaml:"nodes" json:"nodes"`
-	TimeoutMs int    `yaml:"timeoutMs" json:"timeoutMs"`
+	Route        string          `yaml:"route" json:"route"`
+	Protocol     string          `yaml:"protocol" json:"protocol"` // evm|btc
+	Nodes        []Node          `yaml:"nodes" json:"nodes"`
+	TimeoutMs    int             `yaml:"timeoutMs" json:"timeoutMs"`
+	MaxBatchSize int             `yaml:"maxBatchSize" json:"maxBatchSize"` // max JSON-RPC calls per upstream batch, 0 = default
+	Cache        CacheConfig     `yaml:"cache" json:"cache"`
+	Hedge        HedgeConfig     `yaml:"hedge" json:"hedge"`
+	Broadcast    BroadcastConfig `yaml:"broadcast" json:"broadcast"`
+	Strategy     string          `yaml:"strategy" json:"strategy,omitempty"` // load-balancing inside a priority tier, see Strategy*
+	Routing      []MethodRoute   `yaml:"routing" json:"routing,omitempty"`   // first matching rule picks the nodes for a call
+	Methods      MethodPolicy    `yaml:"methods" json:"methods"`
+	Quorum       QuorumConfig    `yaml:"quorum" json:"quorum"`
+	MaxLagBlocks int             `yaml:"maxLagBlocks" json:"maxLagBlocks,omitempty"` // 0 = protocol default, negative disables
+	// Chain identity every upstream must report, checked by the health checker
+	ExpectedChainID string `yaml:"expectedChainId" json:"expectedChainId,omitempty"` // evm: eth_chainId, decimal or 0x hex
+	GenesisHash     string `yaml:"genesisHash" json:"genesisHash,omitempty"`         // sol, btc, ltc, doge
+	NetworkID       string `yaml:"networkId" json:"networkId,omitempty"`             // trx: p2pVersion from getnodeinfo
+	// Disabled networks answer 503 until re-enabled; their nodes are still health-checked
+	Disabled bool `yaml:"disabled" json:"disabled,omitempty"`
+}
+
+// Validate checks the optional per-network settings; required fields are checked by callers.
+func (c NetworkConfig) Validate() error {
+	if !IsValidStrategy(c.Strategy) {
+		return fmt.Errorf("unknown strategy %q", c.Strategy)
+	}
+	if err := ValidateRouting(c.Routing); err != nil {
+		return err
+	}
+	if err := c.Methods.Validate(); err != nil {
+		return err
+	}
+	for _, pat := range c.Quorum.Methods {
+		if _, err := path.Match(pat, ""); err != nil {
+			return fmt.Errorf("quorum: bad method pattern %q", pat)
+		}
+	}
+	return c.validateIdentity()
+}
+
+func (c NetworkConfig) validateIdentity() error {
+	proto := strings.ToLower(c.Protocol)
+	if c.ExpectedChainID != "" {
+		if proto != "evm" {
+			return fmt.Errorf("expectedChainId applies to evm networks only")
+		}
+		if _, ok := parseChainID(c.ExpectedChainID); !ok {
+			return fmt.Errorf("bad expectedChainId %q", c.ExpectedChainID)
+		}
+	}
+	if c.GenesisHash != "" {
+		switch proto {
+		case "sol", "btc", "ltc", "doge":
+		default:
+			return fmt.Errorf("genesisHash applies to sol, btc, ltc and doge networks only")
+		}
+	}
+	if c.NetworkID != "" && proto != "trx" {
+		return fmt.Errorf("networkId applies to trx networks only")
+	}
+	return nil
+}
+
+// ChainIdentity returns the value every upstream must report for the network's
+// protocol (a decimal chain id for evm), or "" if the network doesn't pin its chain.
+func (c NetworkConfig) ChainIdentity() string {
+	switch strings.ToLower(c.Protocol) {
+	case "evm":
+		if id, ok := parseChainID(c.ExpectedChainID); ok {
+			return strconv.FormatUint(id, 10)
+		}
+	case "sol":
+		return strings.TrimSpace(c.GenesisHash) // base58, case-sensitive
+	case "btc", "ltc", "doge":
+		return strings.ToLower(strings.TrimSpace(c.GenesisHash))
+	case "trx":
+		return strings.TrimSpace(c.NetworkID)
+	}
+	return ""
+}
+
+func parseChainID(s string) (uint64, bool) {
+	s = strings.TrimSpace(s)
+	var n uint64
+	var err error
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		n, err = strconv.ParseUint(s[2:], 16, 64)
+	} else {
+		n, err = strconv.ParseUint(s, 10, 64)
+	}
+	return n, err == nil
+}
+
+// QuorumConfig makes calls to Methods read from Size upstreams and return the majority answer.
+type QuorumConfig struct {
+	Size    int      `yaml:"size" json:"size"`                 // upstreams per call, 0 = default
+	Methods []string `yaml:"methods" json:"methods,omitempty"` // path.Match patterns
+}
+
+// MethodPolicy limits which JSON-RPC methods clients may call. Patterns are path.Match
+// globs; deny wins over allow and an empty allow list allows everything not denied.
+type MethodPolicy struct {
+	Allow []string `yaml:"allow" json:"allow,omitempty"`
+	Deny  []string `yaml:"deny" json:"deny,omitempty"`
+}
+
+// Permits reports whether method may be called under the policy.
+func (mp MethodPolicy) Permits(method string) bool {
+	if MatchMethod(mp.Deny, method) {
+		return false
+	}
+	return len(mp.Allow) == 0 || MatchMethod(mp.Allow, method)
+}
+
+// MatchMethod reports whether method matches any of the glob patterns.
+func MatchMethod(patterns []string, method string) bool {
+	for _, pat := range patterns {
+		if ok, _ := path.Match(pat, method); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that all patterns compile.
+func (mp MethodPolicy) Validate() error {
+	for _, pat := range append(append([]string{}, mp.Allow...), mp.Deny...) {
+		if _, err := path.Match(pat, ""); err != nil {
+			return fmt.Errorf("methods: bad pattern %q", pat)
+		}
+	}
+	return nil
+}
+
+// MethodRoute sends JSON-RPC calls matching Methods only to nodes carrying all Tags.
+type MethodRoute struct {
+	Methods []string `yaml:"methods" json:"methods"` // path.Match patterns, e.g. debug_*
+	Tags    []string `yaml:"tags" json:"tags"`
+	// MinBlockRange limits an eth_getLogs rule to ranges spanning at least this many blocks
+	MinBlockRange uint64 `yaml:"minBlockRange" json:"minBlockRange,omitempty"`
+}
+
+// ValidateRouting checks that every rule has methods and tags and that patterns compile.
+func ValidateRouting(rules []MethodRoute) error {
+	for i, r := range rules {
+		if len(r.Methods) == 0 || len(r.Tags) == 0 {
+			return fmt.Errorf("routing[%d]: methods and tags are required", i)
+		}
+		for _, m := range r.Methods {
+			if _, err := path.Match(m, ""); err != nil {
+				return fmt.Errorf("routing[%d]: bad method pattern %q", i, m)
+			}
+		}
+	}
+	return nil
+}
+
+// Load-balancing strategies. They order nodes inside a priority tier; tiers are always tried in priority order.
+const (
+	StrategyPriorityFailover = "priority-failover" // fastest node by health ping first, the rest as failover
+	StrategyRoundRobin       = "round-robin"       // rotate the starting node on every request (default)
+	StrategyWeightedRandom   = "weighted-random"   // random order weighted by node weight
+	StrategyLeastLatency     = "least-latency"     // lowest EWMA of observed proxy latency first
+	StrategyLeastInflight    = "least-inflight"    // fewest requests in flight first
+)
+
+func IsValidStrategy(s string) bool {
+	switch s {
+	case "", StrategyPriorityFailover, StrategyRoundRobin, StrategyWeightedRandom, StrategyLeastLatency, StrategyLeastInflight:
+		return true
+	}
+	return false
+}
+
+// CacheConfig enables the JSON-RPC response cache for a network.
+type CacheConfig struct {
+	Enabled       bool `yaml:"enabled" json:"enabled"`
+	MaxEntries    int  `yaml:"maxEntries" json:"maxEntries"`       // LRU bound, 0 = default
+	HeadTTLMs     int  `yaml:"headTtlMs" json:"headTtlMs"`         // TTL for head-dependent calls (eth_blockNumber, eth_gasPrice)
+	FinalityDepth int  `yaml:"finalityDepth" json:"finalityDepth"` // blocks below head considered final
+}
+
+// BroadcastConfig sends transaction submissions to several upstreams at once.
+type BroadcastConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	Fanout  int  `yaml:"fanout" json:"fanout"` // upstreams per submission, 0 = default
+}
+
+// HedgeConfig enables hedged reads: if the first upstream is slow, the next one is tried in parallel.
+type HedgeConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	DelayMs int  `yaml:"delayMs" json:"delayMs"` // wait before firing the hedge, 0 = default
 }
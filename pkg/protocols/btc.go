@@ -0,0 +1,55 @@
+package protocols
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/adapters"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+)
+
+// === BTC ===
+func init() {
+	Register(Family{
+		ID:      "btc",
+		Probe:   btcProbe,
+		Adapter: adapters.BTC,
+		Timeout: 2000 * time.Millisecond,
+		MaxLag:  2,
+		IdentityProbe: func(ctx context.Context, n networks.Node) (*http.Request, error) {
+			u := strings.TrimSuffix(n.URL, "/")
+			if isEsplora(u) {
+				return GetRequest(ctx, u+"/block-height/0")
+			}
+			return RPCRequest(ctx, u, "getblockhash", 0)
+		},
+		ParseIdentity: blockHash,
+		SyncProbe: func(ctx context.Context, n networks.Node) (*http.Request, error) {
+			u := strings.TrimSuffix(n.URL, "/")
+			if isEsplora(u) {
+				return nil, errors.ErrUnsupported // Esplora only serves synced data
+			}
+			return RPCRequest(ctx, u, "getblockchaininfo")
+		},
+		ParseSync: utxoSync,
+	})
+}
+
+// isEsplora reports whether u is a Blockstream Esplora REST API.
+func isEsplora(u string) bool {
+	return strings.Contains(u, "blockstream.info") || strings.HasSuffix(u, "/api")
+}
+
+func btcProbe(ctx context.Context, n networks.Node) (*http.Request, error) {
+	u := strings.TrimSuffix(n.URL, "/")
+	switch {
+	case isEsplora(u):
+		return GetRequest(ctx, u+"/blocks/tip/height")
+	case strings.Contains(u, "gateway.tatum.io"):
+		return RPCRequest(ctx, u, "getblockcount")
+	}
+	return nil, errUnsupportedUpstream
+}
@@ -0,0 +1,77 @@
+package protocols
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/adapters"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+)
+
+// === LTC / DOGE ===
+// Both are probed through Tatum JSON-RPC or the node's Core REST interface.
+func init() {
+	Register(coreFamily("ltc", adapters.LTC))
+	Register(coreFamily("doge", adapters.DOGE))
+}
+
+func coreFamily(id string, adapter adapters.Func) Family {
+	return Family{
+		ID: id,
+		Probe: func(ctx context.Context, n networks.Node) (*http.Request, error) {
+			u := strings.TrimSuffix(n.URL, "/")
+			if strings.Contains(u, "tatum.io") {
+				return RPCRequest(ctx, u, "getblockcount")
+			}
+			return GetRequest(ctx, u+"/rest/chaininfo.json")
+		},
+		Adapter: adapter,
+		Timeout: 2000 * time.Millisecond,
+		MaxLag:  2,
+		IdentityProbe: func(ctx context.Context, n networks.Node) (*http.Request, error) {
+			u := strings.TrimSuffix(n.URL, "/")
+			if strings.Contains(u, "tatum.io") {
+				return RPCRequest(ctx, u, "getblockhash", 0)
+			}
+			return GetRequest(ctx, u+"/rest/blockhashbyheight/0.json")
+		},
+		ParseIdentity: blockHash,
+		SyncProbe: func(ctx context.Context, n networks.Node) (*http.Request, error) {
+			u := strings.TrimSuffix(n.URL, "/")
+			if strings.Contains(u, "tatum.io") {
+				return RPCRequest(ctx, u, "getblockchaininfo")
+			}
+			return GetRequest(ctx, u+"/rest/chaininfo.json")
+		},
+		ParseSync: utxoSync,
+	}
+}
+
+// utxoSync reads getblockchaininfo (JSON-RPC or Core REST chaininfo.json): a node in
+// initial block download serves stale data.
+func utxoSync(reply []byte) (string, error) {
+	raw := json.RawMessage(reply)
+	if res, err := RPCResult(reply); err == nil {
+		raw = res
+	}
+	var info struct {
+		InitialBlockDownload *bool  `json:"initialblockdownload"`
+		Blocks               uint64 `json:"blocks"`
+		Headers              uint64 `json:"headers"`
+	}
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return "", err
+	}
+	if info.InitialBlockDownload == nil {
+		return "", errors.New("no initialblockdownload in reply")
+	}
+	if *info.InitialBlockDownload {
+		return fmt.Sprintf("syncing: initial block download, block %d of %d", info.Blocks, info.Headers), nil
+	}
+	return "", nil
+}
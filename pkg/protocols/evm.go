@@ -0,0 +1,80 @@
+package protocols
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/adapters"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+)
+
+// === EVM ===
+func init() {
+	Register(Family{
+		ID: "evm",
+		Probe: func(ctx context.Context, n networks.Node) (*http.Request, error) {
+			return RPCRequest(ctx, n.URL, "eth_blockNumber")
+		},
+		ParseHead: evmHead,
+		Adapter:   adapters.EVM,
+		Timeout:   1500 * time.Millisecond,
+		MaxLag:    50,
+		IdentityProbe: func(ctx context.Context, n networks.Node) (*http.Request, error) {
+			return RPCRequest(ctx, n.URL, "eth_chainId")
+		},
+		ParseIdentity: evmChainID,
+		SyncProbe: func(ctx context.Context, n networks.Node) (*http.Request, error) {
+			return RPCRequest(ctx, n.URL, "eth_syncing")
+		},
+		ParseSync: evmSync,
+	})
+}
+
+// evmHead requires a result: nodes answering eth_blockNumber with an error are unhealthy.
+func evmHead(reply []byte) (uint64, error) {
+	s, err := rpcString(reply)
+	if err != nil {
+		return 0, err
+	}
+	return parseHex(s), nil
+}
+
+// evmChainID returns eth_chainId as a decimal string.
+func evmChainID(reply []byte) (string, error) {
+	s, err := rpcString(reply)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(s, "0x") {
+		return "", fmt.Errorf("bad chain id %q", s)
+	}
+	id, err := strconv.ParseUint(s[2:], 16, 64)
+	if err != nil {
+		return "", fmt.Errorf("bad chain id %q", s)
+	}
+	return strconv.FormatUint(id, 10), nil
+}
+
+// evmSync reads eth_syncing: false, or the sync progress while the node catches up.
+func evmSync(reply []byte) (string, error) {
+	res, err := RPCResult(reply)
+	if err != nil {
+		return "", err
+	}
+	if string(res) == "false" {
+		return "", nil
+	}
+	var p struct {
+		CurrentBlock string `json:"currentBlock"`
+		HighestBlock string `json:"highestBlock"`
+	}
+	if err := json.Unmarshal(res, &p); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("syncing: block %d of %d", parseHex(p.CurrentBlock), parseHex(p.HighestBlock)), nil
+}
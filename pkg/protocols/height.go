@@ -0,0 +1,64 @@
+package protocols
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// ParseHeight understands the replies of all probes: JSON-RPC results (number or hex
+// quantity), plain numbers (Esplora tip height), bitcoind chaininfo.json and TRON
+// getnowblock / getnodeinfo.
+func ParseHeight(b []byte) uint64 {
+	s := strings.TrimSpace(string(b))
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return n
+	}
+	var v struct {
+		Result      json.RawMessage `json:"result"`
+		Blocks      uint64          `json:"blocks"`
+		BlockHeader struct {
+			RawData struct {
+				Number uint64 `json:"number"`
+			} `json:"raw_data"`
+		} `json:"block_header"`
+		Block string `json:"block"` // TRON getnodeinfo: "Num:123,ID:..."
+	}
+	if json.Unmarshal(b, &v) != nil {
+		return 0
+	}
+	switch {
+	case len(v.Result) > 0:
+		return parseQuantity(v.Result)
+	case v.Blocks > 0:
+		return v.Blocks
+	case v.BlockHeader.RawData.Number > 0:
+		return v.BlockHeader.RawData.Number
+	case strings.HasPrefix(v.Block, "Num:"):
+		num, _, _ := strings.Cut(strings.TrimPrefix(v.Block, "Num:"), ",")
+		n, _ := strconv.ParseUint(num, 10, 64)
+		return n
+	}
+	return 0
+}
+
+// parseQuantity decodes a JSON number or a "0x"-prefixed hex string.
+func parseQuantity(raw json.RawMessage) uint64 {
+	var n uint64
+	if json.Unmarshal(raw, &n) == nil {
+		return n
+	}
+	var s string
+	if json.Unmarshal(raw, &s) != nil {
+		return 0
+	}
+	return parseHex(s)
+}
+
+func parseHex(s string) uint64 {
+	if !strings.HasPrefix(s, "0x") {
+		return 0
+	}
+	n, _ := strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 64)
+	return n
+}
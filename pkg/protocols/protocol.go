@@ -0,0 +1,214 @@
+// Package protocols describes chain families (evm, btc, trx, ...): how their upstreams
+// are probed, how the chain head is read from a probe and how client requests are
+// adapted. Built-in families register themselves on import; a program embedding the
+// forwarder adds its own with Register before the health checker starts.
+package protocols
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/adapters"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+)
+
+const (
+	fallbackTimeout = 1500 * time.Millisecond
+	fallbackMaxLag  = 2
+)
+
+// Protocol is a chain family, named by `protocol` in network configs.
+type Protocol interface {
+	Name() string
+	// ProbeRequest builds the health request for n; node headers are added by the
+	// checker. An error means n can't be probed (e.g. an unsupported provider).
+	ProbeRequest(ctx context.Context, n networks.Node) (*http.Request, error)
+	// Head reads the block height (or slot) from a successful probe reply; 0 means
+	// unknown. An error marks the node unhealthy.
+	Head(reply []byte) (uint64, error)
+	// Adapt rewrites a client request for the upstreams.
+	Adapt(baseURL, tail, method string, hdr http.Header, body []byte, logger *zap.Logger) adapters.Result
+	// DefaultTimeout is the per-node timeout when the network sets no timeoutMs.
+	DefaultTimeout() time.Duration
+	// DefaultMaxLag is how far behind the head a node may be when the network sets no maxLagBlocks.
+	DefaultMaxLag() uint64
+}
+
+// Identifier is implemented by protocols that can tell which chain an upstream serves.
+// The identity must be in the form networks.NetworkConfig.ChainIdentity returns.
+type Identifier interface {
+	IdentityRequest(ctx context.Context, n networks.Node) (*http.Request, error)
+	Identity(reply []byte) (string, error)
+}
+
+// SyncChecker is implemented by protocols that can tell whether an upstream is still
+// syncing or otherwise degraded although it answers the liveness probe.
+type SyncChecker interface {
+	SyncRequest(ctx context.Context, n networks.Node) (*http.Request, error)
+	// SyncStatus returns why the node must not get traffic ("syncing: ..."), or "" if it is in sync.
+	SyncStatus(reply []byte) (string, error)
+}
+
+// Family is a Protocol (and Identifier, SyncChecker) assembled from functions; optional
+// ones fall back to sensible defaults. The built-in families are Families.
+type Family struct {
+	ID    string
+	Probe func(ctx context.Context, n networks.Node) (*http.Request, error)
+	// ParseHead defaults to ParseHeight
+	ParseHead func(reply []byte) (uint64, error)
+	// Adapter defaults to adapters.Passthrough
+	Adapter adapters.Func
+	// Timeout defaults to 1.5s, MaxLag to 2 blocks
+	Timeout time.Duration
+	MaxLag  uint64
+	// IdentityProbe and ParseIdentity enable chain identity checks
+	IdentityProbe func(ctx context.Context, n networks.Node) (*http.Request, error)
+	ParseIdentity func(reply []byte) (string, error)
+	// SyncProbe and ParseSync enable sync-status checks
+	SyncProbe func(ctx context.Context, n networks.Node) (*http.Request, error)
+	ParseSync func(reply []byte) (string, error)
+}
+
+func (f Family) Name() string { return f.ID }
+
+func (f Family) ProbeRequest(ctx context.Context, n networks.Node) (*http.Request, error) {
+	if f.Probe == nil {
+		return nil, errors.ErrUnsupported
+	}
+	return f.Probe(ctx, n)
+}
+
+func (f Family) Head(reply []byte) (uint64, error) {
+	if f.ParseHead == nil {
+		return ParseHeight(reply), nil
+	}
+	return f.ParseHead(reply)
+}
+
+func (f Family) Adapt(baseURL, tail, method string, hdr http.Header, body []byte, logger *zap.Logger) adapters.Result {
+	if f.Adapter == nil {
+		return adapters.Passthrough(baseURL, tail, method, hdr, body, logger)
+	}
+	return f.Adapter(baseURL, tail, method, hdr, body, logger)
+}
+
+func (f Family) DefaultTimeout() time.Duration {
+	if f.Timeout <= 0 {
+		return fallbackTimeout
+	}
+	return f.Timeout
+}
+
+func (f Family) DefaultMaxLag() uint64 {
+	if f.MaxLag == 0 {
+		return fallbackMaxLag
+	}
+	return f.MaxLag
+}
+
+func (f Family) IdentityRequest(ctx context.Context, n networks.Node) (*http.Request, error) {
+	if f.IdentityProbe == nil {
+		return nil, errors.ErrUnsupported
+	}
+	return f.IdentityProbe(ctx, n)
+}
+
+func (f Family) Identity(reply []byte) (string, error) {
+	if f.ParseIdentity == nil {
+		return "", errors.ErrUnsupported
+	}
+	return f.ParseIdentity(reply)
+}
+
+func (f Family) SyncRequest(ctx context.Context, n networks.Node) (*http.Request, error) {
+	if f.SyncProbe == nil {
+		return nil, errors.ErrUnsupported
+	}
+	return f.SyncProbe(ctx, n)
+}
+
+func (f Family) SyncStatus(reply []byte) (string, error) {
+	if f.ParseSync == nil {
+		return "", errors.ErrUnsupported
+	}
+	return f.ParseSync(reply)
+}
+
+var (
+	mu        sync.RWMutex
+	protocols = map[string]Protocol{}
+	// networkAdapters override the protocol's adapter for single networks
+	networkAdapters = map[string]adapters.Func{
+		"nft": adapters.NFT,
+	}
+)
+
+// Register makes a protocol available under its name. It panics if the name is empty
+// or already taken, like database/sql.Register.
+func Register(p Protocol) {
+	name := strings.ToLower(p.Name())
+	if name == "" {
+		panic("protocols: Register with an empty name")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if _, dup := protocols[name]; dup {
+		panic("protocols: Register called twice for " + name)
+	}
+	protocols[name] = p
+}
+
+// Lookup returns the protocol registered under name.
+func Lookup(name string) (Protocol, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := protocols[strings.ToLower(name)]
+	return p, ok
+}
+
+// Names lists the registered protocols.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]string, 0, len(protocols))
+	for name := range protocols {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Adapt rewrites a client request for the network's upstreams with the network's own
+// adapter, if any, or its protocol's. Unknown protocols pass the request through.
+func Adapt(network, protocol, baseURL, tail, method string, hdr http.Header, body []byte, logger *zap.Logger) adapters.Result {
+	if fn, ok := networkAdapters[strings.ToLower(network)]; ok {
+		return fn(baseURL, tail, method, hdr, body, logger)
+	}
+	if p, ok := Lookup(protocol); ok {
+		return p.Adapt(baseURL, tail, method, hdr, body, logger)
+	}
+	return adapters.Passthrough(baseURL, tail, method, hdr, body, logger)
+}
+
+// Timeout returns the protocol's default per-node timeout.
+func Timeout(protocol string) time.Duration {
+	if p, ok := Lookup(protocol); ok {
+		return p.DefaultTimeout()
+	}
+	return fallbackTimeout
+}
+
+// MaxLag returns the protocol's default head lag limit.
+func MaxLag(protocol string) uint64 {
+	if p, ok := Lookup(protocol); ok {
+		return p.DefaultMaxLag()
+	}
+	return fallbackMaxLag
+}
@@ -0,0 +1,70 @@
+package protocols
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/adapters"
+)
+
+func TestParseHeight(t *testing.T) {
+	require.EqualValues(t, 0x10, ParseHeight([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x10"}`)))
+	require.EqualValues(t, 250000000, ParseHeight([]byte(`{"jsonrpc":"2.0","id":1,"result":250000000}`)))
+	require.EqualValues(t, 840000, ParseHeight([]byte("840000\n")))
+	require.EqualValues(t, 2600000, ParseHeight([]byte(`{"chain":"main","blocks":2600000,"headers":2600000}`)))
+	require.EqualValues(t, 61000000, ParseHeight([]byte(`{"blockID":"00","block_header":{"raw_data":{"number":61000000}}}`)))
+	require.EqualValues(t, 61000000, ParseHeight([]byte(`{"block":"Num:61000000,ID:0000"}`)))
+	require.Zero(t, ParseHeight([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000}}`)))
+}
+
+func TestRegistry(t *testing.T) {
+	require.Equal(t, []string{"btc", "doge", "evm", "ltc", "sol", "trx"}, Names())
+	require.Equal(t, 800*time.Millisecond, Timeout("sol"))
+	require.EqualValues(t, 50, MaxLag("EVM"))
+	require.Equal(t, 1500*time.Millisecond, Timeout("unknown"))
+	require.Panics(t, func() { Register(Family{ID: "evm"}) })
+
+	Register(Family{
+		ID: "acme",
+		Adapter: func(_, tail, method string, _ http.Header, body []byte, _ *zap.Logger) adapters.Result {
+			return adapters.Result{Tail: "acme/" + tail, Method: method, Body: body}
+		},
+	})
+	defer func() {
+		mu.Lock()
+		delete(protocols, "acme")
+		mu.Unlock()
+	}()
+	ad := Adapt("acme-main", "acme", "", "status", http.MethodGet, nil, nil, zap.NewNop())
+	require.Equal(t, "acme/status", ad.Tail)
+	require.EqualValues(t, 2, MaxLag("acme"))
+
+	// unknown protocols pass requests through
+	ad = Adapt("x", "unknown", "", "a/b", http.MethodGet, nil, nil, zap.NewNop())
+	require.Equal(t, "a/b", ad.Tail)
+}
+
+func TestIdentity(t *testing.T) {
+	evm, _ := Lookup("evm")
+	id, err := evm.(Identifier).Identity([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x38"}`))
+	require.NoError(t, err)
+	require.Equal(t, "56", id)
+
+	btc, _ := Lookup("btc")
+	genesis := "000000000019D6689C085AE165831E934FF763AE46A2A6C172B3F1B60A8CE26F"
+	for _, reply := range []string{genesis + "\n", `{"jsonrpc":"2.0","id":1,"result":"` + genesis + `"}`, `{"blockhash":"` + genesis + `"}`} {
+		id, err = btc.(Identifier).Identity([]byte(reply))
+		require.NoError(t, err)
+		require.Equal(t, strings.ToLower(genesis), id)
+	}
+
+	trx, _ := Lookup("trx")
+	id, err = trx.(Identifier).Identity([]byte(`{"configNodeInfo":{"p2pVersion":11111}}`))
+	require.NoError(t, err)
+	require.Equal(t, "11111", id)
+}
@@ -0,0 +1,83 @@
+package protocols
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// errUnsupportedUpstream is returned for upstreams a family doesn't know how to probe.
+var errUnsupportedUpstream = errors.New("unsupported upstream")
+
+// RPCRequest builds a JSON-RPC 2.0 POST of method to url.
+func RPCRequest(ctx context.Context, url, method string, params ...any) (*http.Request, error) {
+	if params == nil {
+		params = []any{}
+	}
+	js, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(js))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("content-type", "application/json")
+	return req, nil
+}
+
+// GetRequest builds a plain GET of url.
+func GetRequest(ctx context.Context, url string) (*http.Request, error) {
+	return http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+}
+
+// RPCResult returns the result of a JSON-RPC reply, or its error.
+func RPCResult(reply []byte) (json.RawMessage, error) {
+	var out struct {
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(reply, &out); err != nil {
+		return nil, err
+	}
+	if len(out.Error) > 0 && string(out.Error) != "null" {
+		return nil, fmt.Errorf("rpc error: %s", out.Error)
+	}
+	if len(out.Result) == 0 || string(out.Result) == "null" {
+		return nil, errors.New("empty result")
+	}
+	return out.Result, nil
+}
+
+// rpcString returns a JSON-RPC result that must be a non-empty string.
+func rpcString(reply []byte) (string, error) {
+	res, err := RPCResult(reply)
+	if err != nil {
+		return "", err
+	}
+	var s string
+	if json.Unmarshal(res, &s) != nil || s == "" {
+		return "", fmt.Errorf("unexpected result %s", res)
+	}
+	return s, nil
+}
+
+// blockHash reads a block hash from a JSON-RPC result, a {"blockhash": ...} object
+// (Core REST) or a bare hash (Esplora), lowercased.
+func blockHash(reply []byte) (string, error) {
+	if s, err := rpcString(reply); err == nil {
+		return strings.ToLower(s), nil
+	}
+	var rest struct {
+		BlockHash string `json:"blockhash"`
+	}
+	if json.Unmarshal(reply, &rest) == nil && rest.BlockHash != "" {
+		return strings.ToLower(rest.BlockHash), nil
+	}
+	s := strings.TrimSpace(string(reply))
+	if len(s) != 64 || strings.Trim(strings.ToLower(s), "0123456789abcdef") != "" {
+		return "", errors.New("no block hash in reply")
+	}
+	return strings.ToLower(s), nil
+}
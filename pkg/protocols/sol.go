@@ -0,0 +1,53 @@
+package protocols
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/adapters"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+)
+
+// === SOL ===
+func init() {
+	Register(Family{
+		ID: "sol",
+		Probe: func(ctx context.Context, n networks.Node) (*http.Request, error) {
+			return RPCRequest(ctx, n.URL, "getSlot")
+		},
+		Adapter: adapters.SOL,
+		Timeout: 800 * time.Millisecond,
+		MaxLag:  150, // slots, ~1 minute
+		IdentityProbe: func(ctx context.Context, n networks.Node) (*http.Request, error) {
+			return RPCRequest(ctx, n.URL, "getGenesisHash")
+		},
+		ParseIdentity: rpcString, // base58, case-sensitive
+		SyncProbe: func(ctx context.Context, n networks.Node) (*http.Request, error) {
+			return RPCRequest(ctx, n.URL, "getHealth")
+		},
+		ParseSync: solHealth,
+	})
+}
+
+// solHealth reads getHealth: "ok", or an error such as "Node is behind by 42 slots".
+func solHealth(reply []byte) (string, error) {
+	var out struct {
+		Result string `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(reply, &out); err != nil {
+		return "", err
+	}
+	if out.Error != nil {
+		return "unhealthy: " + out.Error.Message, nil
+	}
+	if out.Result != "ok" {
+		return "", fmt.Errorf("unexpected getHealth result %q", out.Result)
+	}
+	return "", nil
+}
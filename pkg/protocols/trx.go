@@ -0,0 +1,95 @@
+package protocols
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/adapters"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+)
+
+// === TRX ===
+func init() {
+	Register(Family{
+		ID:      "trx",
+		Probe:   trxProbe,
+		Adapter: adapters.TRX,
+		Timeout: 1500 * time.Millisecond,
+		MaxLag:  20,
+		IdentityProbe: func(ctx context.Context, n networks.Node) (*http.Request, error) {
+			return GetRequest(ctx, strings.TrimSuffix(n.URL, "/")+"/wallet/getnodeinfo")
+		},
+		ParseIdentity: trxNetworkID,
+		SyncProbe: func(ctx context.Context, n networks.Node) (*http.Request, error) {
+			return GetRequest(ctx, strings.TrimSuffix(n.URL, "/")+"/wallet/getnodeinfo")
+		},
+		ParseSync: trxSync,
+	})
+}
+
+func trxProbe(ctx context.Context, n networks.Node) (*http.Request, error) {
+	u := strings.TrimSuffix(n.URL, "/")
+	// Tatum REST
+	if strings.Contains(u, "tatum.io") {
+		return GetRequest(ctx, u+"/wallet/getnodeinfo")
+	}
+	// TronGrid / обычный FullNode API
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u+"/wallet/getnowblock", strings.NewReader(`{}`))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// trxNetworkID reads configNodeInfo.p2pVersion from getnodeinfo.
+func trxNetworkID(reply []byte) (string, error) {
+	var out struct {
+		ConfigNodeInfo struct {
+			P2PVersion json.Number `json:"p2pVersion"`
+		} `json:"configNodeInfo"`
+	}
+	if json.Unmarshal(reply, &out) != nil || out.ConfigNodeInfo.P2PVersion == "" {
+		return "", errors.New("no p2pVersion in getnodeinfo")
+	}
+	return out.ConfigNodeInfo.P2PVersion.String(), nil
+}
+
+// trxSyncLag is how many blocks a node may still have to fetch from its peers.
+const trxSyncLag = 20
+
+// trxSync reads getnodeinfo: a node without peers, or with many blocks left to fetch
+// from them, is syncing.
+func trxSync(reply []byte) (string, error) {
+	var info struct {
+		ActiveConnectCount *int `json:"activeConnectCount"`
+		PeerList           []struct {
+			NeedSyncFromPeer bool   `json:"needSyncFromPeer"`
+			RemainNum        uint64 `json:"remainNum"`
+		} `json:"peerList"`
+	}
+	if err := json.Unmarshal(reply, &info); err != nil {
+		return "", err
+	}
+	if info.ActiveConnectCount == nil {
+		return "", errors.New("no activeConnectCount in getnodeinfo")
+	}
+	if *info.ActiveConnectCount == 0 {
+		return "degraded: no active peers", nil
+	}
+	var remain uint64
+	for _, p := range info.PeerList {
+		if p.NeedSyncFromPeer {
+			remain = max(remain, p.RemainNum)
+		}
+	}
+	if remain > trxSyncLag {
+		return fmt.Sprintf("syncing: %d blocks left to fetch from peers", remain), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,107 @@
+package registry
+
+import "time"
+
+// Circuit breaker thresholds, shared by all upstreams.
+const (
+	breakerFailures   = 5                // consecutive failures that open the circuit
+	breakerOpenFor    = 30 * time.Second // how long an open circuit rejects traffic
+	breakerProbeEvery = 5 * time.Second  // spacing of trial requests while half-open
+)
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+type breaker struct {
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probeAt  time.Time
+}
+
+// Ready reports whether a request may be sent to the upstream: an open circuit is ready
+// for a trial after breakerOpenFor, a half-open one once per breakerProbeEvery. A tripped
+// circuit hands out each trial once: the caller that gets true holds it, the circuit turns
+// half-open and the next trial is due breakerProbeEvery later.
+func (s *NodeStats) Ready() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	switch s.cb.state {
+	case BreakerOpen:
+		if now.Sub(s.cb.openedAt) < breakerOpenFor {
+			return false
+		}
+	case BreakerHalfOpen:
+		if now.Sub(s.cb.probeAt) < breakerProbeEvery {
+			return false
+		}
+	default:
+		return true
+	}
+	s.cb.state = BreakerHalfOpen
+	s.cb.probeAt = now
+	return true
+}
+
+// Dispatch records that a request is being sent to the upstream. A tripped circuit turns
+// half-open and the next trial is due breakerProbeEvery later; this covers requests sent
+// to tripped nodes without a trial, when every circuit of the network is open. It returns
+// the new state.
+func (s *NodeStats) Dispatch() BreakerState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cb.state != BreakerClosed {
+		s.cb.state = BreakerHalfOpen
+		s.cb.probeAt = time.Now()
+	}
+	return s.cb.state
+}
+
+// Success closes the circuit and resets the cooldown backoff. It returns the
+// circuit state before the call.
+func (s *NodeStats) Success() BreakerState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev := s.cb.state
+	s.cb = breaker{}
+	s.limited = 0
+	return prev
+}
+
+// Failure records a failed request and opens the circuit after breakerFailures in a row,
+// or immediately when a half-open trial fails. It returns the new state.
+func (s *NodeStats) Failure() BreakerState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cb.failures++
+	if s.cb.state == BreakerHalfOpen || s.cb.failures >= breakerFailures {
+		if s.cb.state != BreakerOpen {
+			s.cb.openedAt = time.Now()
+		}
+		s.cb.state = BreakerOpen
+	}
+	return s.cb.state
+}
+
+func (s *NodeStats) Breaker() BreakerState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cb.state
+}
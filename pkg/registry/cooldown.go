@@ -0,0 +1,42 @@
+package registry
+
+import "time"
+
+// Cooldown bounds for throttled upstreams.
+const (
+	cooldownBase = time.Second     // first backoff when the provider gives no hint
+	cooldownMax  = 5 * time.Minute // longest cooldown we accept from a provider
+)
+
+// CoolDown keeps the upstream out of rotation for d. When d is zero the backoff doubles
+// from cooldownBase with every rate limit in a row. It returns the applied duration.
+func (s *NodeStats) CoolDown(d time.Duration) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limited++
+	if d <= 0 {
+		d = cooldownBase << min(s.limited-1, 8)
+	}
+	d = min(d, cooldownMax)
+	if until := time.Now().Add(d); until.After(s.coolUntil) {
+		s.coolUntil = until
+	}
+	return d
+}
+
+// CooldownUntil returns the end of the current cooldown, if any.
+func (s *NodeStats) CooldownUntil() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Now().Before(s.coolUntil) {
+		return s.coolUntil, true
+	}
+	return time.Time{}, false
+}
+
+// CoolingDown reports whether the upstream is throttled and should be neither
+// proxied to nor probed.
+func (r *Registry) CoolingDown(url string) bool {
+	_, ok := r.Stats(url).CooldownUntil()
+	return ok
+}
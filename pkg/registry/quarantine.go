@@ -0,0 +1,39 @@
+package registry
+
+import "time"
+
+// Quarantine bounds for upstreams failing with fatal errors (DNS, TLS, 4xx, ...).
+const (
+	quarantineBase = time.Minute // first re-probe delay
+	quarantineMax  = time.Hour   // longest delay between re-probes
+)
+
+// Quarantine takes the upstream out of rotation after a fatal probe failure. The
+// re-probe delay doubles from quarantineBase with every failure in a row, up to
+// quarantineMax. It returns the delay and the number of failures in a row.
+func (s *NodeStats) Quarantine() (time.Duration, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.strikes++
+	d := min(quarantineBase<<min(s.strikes-1, 10), quarantineMax)
+	s.retryAt = time.Now().Add(d)
+	return d, s.strikes
+}
+
+// QuarantinedUntil returns when a quarantined upstream is probed again; the time may
+// be past while the re-probe is pending.
+func (s *NodeStats) QuarantinedUntil() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.retryAt, s.strikes > 0
+}
+
+// Release ends the quarantine and reports whether the upstream was quarantined.
+func (s *NodeStats) Release() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	was := s.strikes > 0
+	s.strikes = 0
+	s.retryAt = time.Time{}
+	return was
+}
@@ -1,6 +1,8 @@
 package registry
 
 import (
+	"fmt"
+	"reflect"
 	"sort"
 	"strings"
 	"time"
@@ -8,7 +10,46 @@ import (
 	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
 )
 
-func New() *Registry { return &Registry{State: map[string]*NetworkState{}} }
+func New() *Registry {
+	return &Registry{State: map[string]*NetworkState{}, routes: map[string]string{}, stats: map[string]*NodeStats{}}
+}
+
+// set registers st under name and indexes its route. Callers hold r.mu.
+func (r *Registry) set(name string, st *NetworkState) {
+	r.drop(name)
+	r.State[name] = st
+	r.routes[routeKey(name, st.Route)] = name
+}
+
+// drop unregisters name and its route. Callers hold r.mu.
+func (r *Registry) drop(name string) {
+	old, ok := r.State[name]
+	if !ok {
+		return
+	}
+	if key := routeKey(name, old.Route); r.routes[key] == name {
+		delete(r.routes, key)
+	}
+	delete(r.State, name)
+}
+
+// routeKey is the path segment a network is served under: its route, or its name for
+// networks without one.
+func routeKey(name, route string) string {
+	if key := strings.Trim(route, "/"); key != "" {
+		return key
+	}
+	return name
+}
+
+// Resolve returns the name of the network served under route, the first segment of a
+// request path.
+func (r *Registry) Resolve(route string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	name, ok := r.routes[strings.Trim(route, "/")]
+	return name, ok
+}
 
 func (r *Registry) InitFromConfigs(cfgs map[string]networks.NetworkConfig) {
 	r.mu.Lock()
@@ -16,13 +57,30 @@ func (r *Registry) InitFromConfigs(cfgs map[string]networks.NetworkConfig) {
 	for name, c := range cfgs {
 		copyNodes := make([]networks.Node, len(c.Nodes))
 		copy(copyNodes, c.Nodes)
-		r.State[name] = &NetworkState{
-			Protocol:  c.Protocol,
-			Route:     c.Route,
-			TimeoutMs: c.TimeoutMs,
-			All:       copyNodes,
-			Best:      nil,
-		}
+		c.Nodes = copyNodes
+		r.set(name, newNetworkState(c, nil))
+	}
+}
+
+func newNetworkState(c networks.NetworkConfig, best []NodeWithPing) *NetworkState {
+	return &NetworkState{
+		Protocol:      c.Protocol,
+		Route:         c.Route,
+		TimeoutMs:     c.TimeoutMs,
+		MaxBatchSize:  c.MaxBatchSize,
+		Cache:         c.Cache,
+		Hedge:         c.Hedge,
+		Broadcast:     c.Broadcast,
+		Strategy:      c.Strategy,
+		Routing:       c.Routing,
+		Methods:       c.Methods,
+		Quorum:        c.Quorum,
+		MaxLagBlocks:  c.MaxLagBlocks,
+		ChainIdentity: c.ChainIdentity(),
+		Disabled:      c.Disabled,
+		Config:        c,
+		All:           c.Nodes,
+		Best:          best,
 	}
 }
 
@@ -55,38 +113,148 @@ func (r *Registry) All() map[string]*NetworkState {
 	return out
 }
 
-func PickFastestPerPriority(nodes []NodeWithPing) []NodeWithPing {
-	m := map[int][]NodeWithPing{}
+// PickHealthyTiers keeps every alive node ordered by priority tier and, inside a tier, by ping.
+func PickHealthyTiers(nodes []NodeWithPing) []NodeWithPing {
+	best := make([]NodeWithPing, 0, len(nodes))
 	for _, n := range nodes {
-		if !n.Alive {
-			continue
-		}
-		m[n.Priority] = append(m[n.Priority], n)
-	}
-	var best []NodeWithPing
-	for _, group := range m {
-		fastest := group[0]
-		for _, cur := range group[1:] {
-			if cur.Ping < fastest.Ping {
-				fastest = cur
-			}
+		if n.Alive {
+			best = append(best, n)
 		}
-		best = append(best, fastest)
 	}
-	sort.Slice(best, func(i, j int) bool { return best[i].Priority < best[j].Priority })
+	sortTiered(best)
 	return best
 }
+
+// Tiers splits a tiered list (see PickHealthyTiers) into per-priority groups.
+func Tiers(nodes []NodeWithPing) [][]NodeWithPing {
+	var tiers [][]NodeWithPing
+	for i, n := range nodes {
+		if i == 0 || n.Priority != nodes[i-1].Priority {
+			tiers = append(tiers, nil)
+		}
+		tiers[len(tiers)-1] = append(tiers[len(tiers)-1], n)
+	}
+	return tiers
+}
+
+func sortTiered(nodes []NodeWithPing) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].Priority != nodes[j].Priority {
+			return nodes[i].Priority < nodes[j].Priority
+		}
+		return nodes[i].Ping < nodes[j].Ping
+	})
+}
+
 func (r *Registry) AddNetwork(cfg networks.NetworkConfig, best []NodeWithPing) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	key := strings.TrimPrefix(cfg.Route, "/")
-	r.State[key] = &NetworkState{
-		Protocol:  cfg.Protocol,
-		Route:     cfg.Route,
-		TimeoutMs: cfg.TimeoutMs,
-		All:       cfg.Nodes,
-		Best:      best,
+	r.set(key, newNetworkState(cfg, best))
+}
+
+// ReplaceNetwork rebuilds the network registered as name from cfg, under cfg's route,
+// which may differ from name. Gossip-discovered nodes are kept. It fails if name isn't
+// registered or the new route is taken by another network.
+func (r *Registry) ReplaceNetwork(name string, cfg networks.NetworkConfig, best []NodeWithPing) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old, ok := r.State[name]
+	if !ok {
+		return fmt.Errorf("unknown network %q", name)
+	}
+	key := strings.TrimPrefix(cfg.Route, "/")
+	if _, taken := r.State[key]; taken && key != name {
+		return fmt.Errorf("network %q already exists", key)
+	}
+	st := newNetworkState(cfg, best)
+	st.Discovered = old.Discovered
+	r.drop(name)
+	r.set(key, st)
+	return nil
+}
+
+// ApplyConfig registers cfg under name, updating the network in place if it exists.
+// Health results are kept for nodes whose config didn't change; changed and new nodes
+// wait for the next health round. Gossip-discovered nodes are kept.
+func (r *Registry) ApplyConfig(name string, cfg networks.NetworkConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old, ok := r.State[name]
+	if !ok {
+		r.set(name, newNetworkState(cfg, nil))
+		return
+	}
+	nodes := make(map[string]networks.Node, len(cfg.Nodes))
+	for _, n := range cfg.Nodes {
+		nodes[n.URL] = n
+	}
+	best := make([]NodeWithPing, 0, len(old.Best))
+	for _, b := range old.Best {
+		if n, ok := nodes[b.URL]; ok && reflect.DeepEqual(n, b.Node) {
+			best = append(best, b)
+		}
+	}
+	st := newNetworkState(cfg, best)
+	st.Discovered = old.Discovered
+	st.Status = old.Status
+	st.Head = old.Head
+	r.set(name, st)
+}
+
+// RemoveNetwork unregisters a network and reports whether it existed.
+func (r *Registry) RemoveNetwork(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.State[name]
+	r.drop(name)
+	return ok
+}
+
+// SetDisabled turns proxying for a network off or back on and reports whether the
+// network exists.
+func (r *Registry) SetDisabled(name string, disabled bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.State[name]
+	if ok {
+		s.Disabled = disabled
+		s.Config.Disabled = disabled
+	}
+	return ok
+}
+
+// Disabled reports whether a network is switched off.
+func (r *Registry) Disabled(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if s, ok := r.State[name]; ok {
+		return s.Disabled
+	}
+	return false
+}
+
+// Config returns the current config of a network: the one it was registered with and
+// its current nodes, without gossip-discovered ones.
+func (r *Registry) Config(name string) (networks.NetworkConfig, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.State[name]
+	if !ok {
+		return networks.NetworkConfig{}, false
+	}
+	discovered := make(map[string]struct{}, len(s.Discovered))
+	for _, dn := range s.Discovered {
+		discovered[dn.Node.URL] = struct{}{}
+	}
+	cfg := s.Config
+	cfg.Nodes = make([]networks.Node, 0, len(s.All))
+	for _, n := range s.All {
+		if _, ok := discovered[n.URL]; !ok {
+			cfg.Nodes = append(cfg.Nodes, n)
+		}
 	}
+	return cfg, true
 }
 
 func (r *Registry) ProtocolOf(name string) string {
@@ -122,7 +290,10 @@ func (r *Registry) AppendBest(net string, n NodeWithPing) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	if s, ok := r.State[net]; ok {
-		s.Best = append(s.Best, n)
+		best := make([]NodeWithPing, 0, len(s.Best)+1)
+		best = append(best, s.Best...)
+		s.Best = append(best, n)
+		sortTiered(s.Best)
 	}
 }
 func (r *Registry) PruneAndMerge(ttl time.Duration) {
@@ -155,11 +326,24 @@ func (r *Registry) PruneAndMerge(ttl time.Duration) {
 		}
 	}
 }
+
+// Has reports whether a network is registered under exactly this name (its route
+// without the leading slash).
+func (r *Registry) Has(network string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.State[network]
+	return ok
+}
+
 func (r *Registry) Exists(route string) bool {
 	route = strings.ToLower(strings.Trim(route, "/"))
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	_, ok := r.State[route]
+	if _, ok := r.State[route]; ok {
+		return true
+	}
+	_, ok := r.routes[route]
 	return ok
 }
 
@@ -170,6 +354,191 @@ func (r *Registry) TimeoutMs(network string) int {
 	return 0
 }
 
+func (r *Registry) MaxBatchSize(network string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if s, ok := r.State[network]; ok {
+		return s.MaxBatchSize
+	}
+	return 0
+}
+
+func (r *Registry) CacheConfig(network string) networks.CacheConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if s, ok := r.State[network]; ok {
+		return s.Cache
+	}
+	return networks.CacheConfig{}
+}
+
+func (r *Registry) HedgeConfig(network string) networks.HedgeConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if s, ok := r.State[network]; ok {
+		return s.Hedge
+	}
+	return networks.HedgeConfig{}
+}
+
+func (r *Registry) BroadcastConfig(network string) networks.BroadcastConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if s, ok := r.State[network]; ok {
+		return s.Broadcast
+	}
+	return networks.BroadcastConfig{}
+}
+
+func (r *Registry) Strategy(network string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if s, ok := r.State[network]; ok {
+		return s.Strategy
+	}
+	return ""
+}
+
+func (r *Registry) Routing(network string) []networks.MethodRoute {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if s, ok := r.State[network]; ok {
+		return s.Routing
+	}
+	return nil
+}
+
+func (r *Registry) MethodPolicy(network string) networks.MethodPolicy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if s, ok := r.State[network]; ok {
+		return s.Methods
+	}
+	return networks.MethodPolicy{}
+}
+
+func (r *Registry) QuorumConfig(network string) networks.QuorumConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if s, ok := r.State[network]; ok {
+		return s.Quorum
+	}
+	return networks.QuorumConfig{}
+}
+
+func (r *Registry) MaxLagBlocks(network string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if s, ok := r.State[network]; ok {
+		return s.MaxLagBlocks
+	}
+	return 0
+}
+
+// Head returns the network head seen by the health checker, 0 if unknown.
+func (r *Registry) Head(network string) uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if s, ok := r.State[network]; ok {
+		return s.Head
+	}
+	return 0
+}
+
+func (r *Registry) SetHead(network string, head uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.State[network]; ok {
+		s.Head = head
+	}
+}
+
+// SetStatus records health results; nodes missing from nodes keep their previous status.
+func (r *Registry) SetStatus(network string, nodes []NodeWithPing) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.State[network]
+	if !ok {
+		return
+	}
+	status := make(map[string]NodeWithPing, len(s.Status)+len(nodes))
+	for url, n := range s.Status {
+		status[url] = n
+	}
+	for _, n := range nodes {
+		status[n.URL] = n
+	}
+	s.Status = status
+}
+
+// NodeStatus returns the last health result per node URL.
+func (r *Registry) NodeStatus(network string) map[string]NodeWithPing {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if s, ok := r.State[network]; ok {
+		return s.Status
+	}
+	return nil
+}
+
+// ChainIdentity returns the chain identity upstreams of the network must report, "" if not pinned.
+func (r *Registry) ChainIdentity(network string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if s, ok := r.State[network]; ok {
+		return s.ChainIdentity
+	}
+	return ""
+}
+
+// RemoveNode removes a node URL from All/Best/Discovered of one network. The slices are
+// rebuilt, so snapshots taken by callers (e.g. a health round) stay intact.
+func (r *Registry) RemoveNode(network, url string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st, ok := r.State[network]
+	if !ok {
+		return
+	}
+	all := make([]networks.Node, 0, len(st.All))
+	for _, n := range st.All {
+		if n.URL != url {
+			all = append(all, n)
+		}
+	}
+	st.All = all
+	best := make([]NodeWithPing, 0, len(st.Best))
+	for _, n := range st.Best {
+		if n.URL != url {
+			best = append(best, n)
+		}
+	}
+	st.Best = best
+	var discovered []DiscoveredNode
+	for _, dn := range st.Discovered {
+		if dn.Node.URL != url {
+			discovered = append(discovered, dn)
+		}
+	}
+	st.Discovered = discovered
+}
+
+// IsDiscovered reports whether url joined the network through gossip.
+func (r *Registry) IsDiscovered(network, url string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	st, ok := r.State[network]
+	if !ok {
+		return false
+	}
+	for _, dn := range st.Discovered {
+		if dn.Node.URL == url {
+			return true
+		}
+	}
+	return false
+}
+
 // RemoveNodeEverywhere removes a node URL from All/Best/Discovered across all networks.
 func (r *Registry) RemoveNodeEverywhere(url string) {
 	r.mu.Lock()
@@ -1,7 +1,10 @@
 package registry
 
 import (
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
 	"github.com/stretchr/testify/require"
@@ -21,6 +24,26 @@ func TestAddAndGetNetwork(t *testing.T) {
 	require.Equal(t, "evm", all["testnet"].Protocol)
 }
 
+func TestResolve(t *testing.T) {
+	r := New()
+	r.InitFromConfigs(map[string]networks.NetworkConfig{"klaytn": {Route: "/klay", Protocol: "evm"}})
+	name, ok := r.Resolve("klay")
+	require.True(t, ok)
+	require.Equal(t, "klaytn", name)
+	_, ok = r.Resolve("klaytn")
+	require.False(t, ok, "a network is served under its route only")
+
+	require.NoError(t, r.ReplaceNetwork("klaytn", networks.NetworkConfig{Route: "/kaia", Protocol: "evm"}, nil))
+	_, ok = r.Resolve("klay")
+	require.False(t, ok)
+	name, _ = r.Resolve("kaia")
+	require.Equal(t, "kaia", name)
+
+	r.RemoveNetwork("kaia")
+	_, ok = r.Resolve("kaia")
+	require.False(t, ok)
+}
+
 func TestSanitizeNodes_MasksSecrets(t *testing.T) {
 	nodes := []NodeWithPing{{
 		Node: networks.Node{
@@ -37,3 +60,118 @@ func TestSanitizeNodes_MasksSecrets(t *testing.T) {
 	require.Equal(t, "***", h["Authorization"])
 	require.Equal(t, "ok", h["Custom"])
 }
+
+func TestPickHealthyTiers_KeepsWholeTier(t *testing.T) {
+	nodes := []NodeWithPing{
+		{Node: networks.Node{URL: "a", Priority: 1}, Alive: true, Ping: 30},
+		{Node: networks.Node{URL: "b", Priority: 2}, Alive: true, Ping: 5},
+		{Node: networks.Node{URL: "c", Priority: 1}, Alive: true, Ping: 10},
+		{Node: networks.Node{URL: "d", Priority: 1}, Alive: false},
+	}
+	best := PickHealthyTiers(nodes)
+	require.Len(t, best, 3)
+	require.Equal(t, []string{"c", "a", "b"}, []string{best[0].URL, best[1].URL, best[2].URL})
+
+	tiers := Tiers(best)
+	require.Len(t, tiers, 2)
+	require.Len(t, tiers[0], 2)
+	require.Equal(t, 2, tiers[1][0].Priority)
+}
+
+func TestNodeStats_Breaker(t *testing.T) {
+	s := &NodeStats{}
+	for i := 0; i < breakerFailures-1; i++ {
+		require.Equal(t, BreakerClosed, s.Failure())
+	}
+	require.Equal(t, BreakerOpen, s.Failure())
+	require.False(t, s.Ready())
+
+	s.cb.openedAt = time.Now().Add(-breakerOpenFor)
+	require.True(t, s.Ready(), "open circuit should let a trial through after breakerOpenFor")
+	require.Equal(t, BreakerHalfOpen, s.Breaker())
+	require.False(t, s.Ready(), "only one trial per breakerProbeEvery")
+	require.Equal(t, BreakerHalfOpen, s.Dispatch())
+
+	s.cb.probeAt = time.Now().Add(-breakerProbeEvery)
+	require.True(t, s.Ready())
+	require.False(t, s.Ready())
+
+	require.Equal(t, BreakerHalfOpen, s.Success())
+	require.Equal(t, BreakerClosed, s.Breaker())
+	require.True(t, s.Ready())
+}
+
+func TestNodeStats_BreakerSingleTrial(t *testing.T) {
+	s := &NodeStats{}
+	for i := 0; i < breakerFailures; i++ {
+		s.Failure()
+	}
+	s.cb.openedAt = time.Now().Add(-breakerOpenFor)
+
+	var trials atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s.Ready() {
+				trials.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	require.EqualValues(t, 1, trials.Load(), "concurrent requests share a single trial")
+}
+
+func TestNodeStats_Quarantine(t *testing.T) {
+	s := &NodeStats{}
+	_, ok := s.QuarantinedUntil()
+	require.False(t, ok)
+	require.False(t, s.Release())
+
+	var delays []time.Duration
+	for range 9 {
+		d, _ := s.Quarantine()
+		delays = append(delays, d)
+	}
+	require.Equal(t, []time.Duration{
+		time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 16 * time.Minute,
+		32 * time.Minute, time.Hour, time.Hour, time.Hour,
+	}, delays)
+	retry, ok := s.QuarantinedUntil()
+	require.True(t, ok)
+	require.WithinDuration(t, time.Now().Add(time.Hour), retry, time.Second)
+
+	require.True(t, s.Release())
+	_, ok = s.QuarantinedUntil()
+	require.False(t, ok)
+}
+
+func TestNodeStats_Score(t *testing.T) {
+	s := &NodeStats{}
+	require.Equal(t, 1.0, s.Score())
+
+	for range scoreMinSamples - 1 {
+		s.Observe(OutcomeRPCError)
+	}
+	require.Less(t, s.Score(), ejectScore)
+	require.False(t, s.Outlier(), "too few samples to eject")
+	s.Observe(OutcomeRPCError)
+	require.True(t, s.Outlier())
+
+	require.Equal(t, ejectBase, s.Eject())
+	_, ejected := s.EjectedUntil()
+	require.True(t, ejected)
+	require.Equal(t, 1.0, s.Score(), "an ejected node returns with a clean score")
+	require.Equal(t, 2*ejectBase, s.Eject())
+
+	// slow but reliable: lower score, never an outlier
+	slow := &NodeStats{}
+	for range scoreMinSamples {
+		slow.Begin()
+		slow.End(time.Second)
+		slow.Observe(OutcomeOK)
+	}
+	require.InDelta(t, 0.5, slow.Score(), 0.01)
+	require.False(t, slow.Outlier())
+}
@@ -0,0 +1,102 @@
+package registry
+
+import "time"
+
+// Passive health scoring from proxied traffic and health probes.
+const (
+	scoreAlpha      = 0.1    // weight of the newest outcome in the rate EWMAs
+	scoreMinSamples = 20     // outcomes needed before a node can be ejected
+	scoreLatencyRef = 1000.0 // ms; a node this slow scores half
+	ejectScore      = 0.5    // reliability below which a node is an outlier
+	ejectBase       = 30 * time.Second
+	ejectMax        = 5 * time.Minute
+)
+
+// Outcome is the result of one request to an upstream, as seen by the proxy or a probe.
+type Outcome int
+
+const (
+	OutcomeOK          Outcome = iota
+	OutcomeFailed              // transport error, timeout or 5xx
+	OutcomeRateLimited         // 429 or a rate-limit error reply
+	OutcomeRPCError            // JSON-RPC error blamed on the node (internal error, or one another node answered)
+)
+
+// score holds the EWMA rate of each bad outcome; guarded by NodeStats.mu.
+type score struct {
+	samples    int
+	failed     float64
+	limited    float64
+	rpcErr     float64
+	ejectUntil time.Time
+	ejections  int // ejections in a row, drives the ejection backoff
+}
+
+// Observe folds one outcome into the node's score.
+func (s *NodeStats) Observe(o Outcome) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc := &s.score
+	sc.failed = ewma(sc.failed, o == OutcomeFailed)
+	sc.limited = ewma(sc.limited, o == OutcomeRateLimited)
+	sc.rpcErr = ewma(sc.rpcErr, o == OutcomeRPCError)
+	sc.samples++
+	if sc.samples >= scoreMinSamples && s.reliability() >= ejectScore {
+		sc.ejections = 0
+	}
+}
+
+func ewma(rate float64, hit bool) float64 {
+	v := 0.0
+	if hit {
+		v = 1
+	}
+	return scoreAlpha*v + (1-scoreAlpha)*rate
+}
+
+// reliability is the success ratio discounted by JSON-RPC errors and, at half weight,
+// rate limits: 1 is a perfect node. Callers hold s.mu.
+func (s *NodeStats) reliability() float64 {
+	sc := &s.score
+	return (1 - sc.failed) * (1 - sc.rpcErr) * (1 - sc.limited/2)
+}
+
+// Score rates the node between 0 and 1 from its reliability and latency EWMA. A node
+// without samples scores 1.
+func (s *NodeStats) Score() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v := s.reliability()
+	if s.sampled {
+		v *= scoreLatencyRef / (scoreLatencyRef + s.latencyMs)
+	}
+	return v
+}
+
+// Outlier reports whether the node has enough samples and its reliability is below
+// ejectScore. Latency alone never makes a node an outlier.
+func (s *NodeStats) Outlier() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.score.samples >= scoreMinSamples && s.reliability() < ejectScore
+}
+
+// Eject takes the node out of rotation; the duration doubles from ejectBase with every
+// ejection in a row, up to ejectMax. The node gets a clean score when it returns.
+func (s *NodeStats) Eject() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := min(ejectBase<<min(s.score.ejections, 8), ejectMax)
+	s.score = score{ejectUntil: time.Now().Add(d), ejections: s.score.ejections + 1}
+	return d
+}
+
+// EjectedUntil returns the end of the current ejection, if any.
+func (s *NodeStats) EjectedUntil() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Now().Before(s.score.ejectUntil) {
+		return s.score.ejectUntil, true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,86 @@
+package registry
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ewmaAlpha is the weight of the newest latency sample.
+const ewmaAlpha = 0.3
+
+// NodeStats holds live figures observed by the proxy for one upstream URL.
+type NodeStats struct {
+	inflight atomic.Int64
+	head     atomic.Uint64 // latest block the upstream is known to have, 0 = unknown
+
+	mu        sync.Mutex
+	latencyMs float64 // EWMA
+	sampled   bool
+	cb        breaker
+	coolUntil time.Time // no traffic before this moment
+	limited   int       // rate limits in a row, drives the cooldown backoff
+	strikes   int       // fatal probe failures in a row, drives the quarantine backoff
+	retryAt   time.Time // next probe of a quarantined upstream
+	score     score
+}
+
+// Stats returns the live stats of an upstream, creating them on first use.
+func (r *Registry) Stats(url string) *NodeStats {
+	r.statsMu.Lock()
+	defer r.statsMu.Unlock()
+	if r.stats == nil {
+		r.stats = map[string]*NodeStats{}
+	}
+	s, ok := r.stats[url]
+	if !ok {
+		s = &NodeStats{}
+		r.stats[url] = s
+	}
+	return s
+}
+
+// Begin marks a request to the upstream as in flight.
+func (s *NodeStats) Begin() { s.inflight.Add(1) }
+
+// End finishes a request started with Begin and folds its latency into the EWMA.
+func (s *NodeStats) End(latency time.Duration) {
+	s.inflight.Add(-1)
+	ms := float64(latency.Milliseconds())
+	s.mu.Lock()
+	if !s.sampled {
+		s.latencyMs = ms
+		s.sampled = true
+	} else {
+		s.latencyMs = ewmaAlpha*ms + (1-ewmaAlpha)*s.latencyMs
+	}
+	s.mu.Unlock()
+}
+
+// Abort finishes a request started with Begin without recording latency (e.g. cancelled).
+func (s *NodeStats) Abort() { s.inflight.Add(-1) }
+
+func (s *NodeStats) Inflight() int64 { return s.inflight.Load() }
+
+// LatencyMs returns the latency EWMA and whether any sample was recorded.
+func (s *NodeStats) LatencyMs() (float64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latencyMs, s.sampled
+}
+
+// Head returns the latest block height the upstream is known to have, 0 if unknown.
+func (s *NodeStats) Head() uint64 { return s.head.Load() }
+
+// SetHead records the height reported by a health probe.
+func (s *NodeStats) SetHead(n uint64) { s.head.Store(n) }
+
+// ObserveHead raises the known height to n, e.g. after the upstream served block n.
+func (s *NodeStats) ObserveHead(n uint64) {
+	for {
+		cur := s.head.Load()
+		if n <= cur || s.head.CompareAndSwap(cur, n) {
+			return
+		}
+	}
+}
@@ -10,12 +10,21 @@ import (
 type Registry struct {
 	mu    sync.RWMutex
 	State map[string]*NetworkState // key: network name (eth, btc)
+	// routes maps each network's route (klay) to its name (klaytn); YAML networks are
+	// named after their file, which need not match the route
+	routes map[string]string
+
+	statsMu sync.Mutex
+	stats   map[string]*NodeStats // key: node URL
 }
 
 type NodeWithPing struct {
 	networks.Node
-	Alive bool  `json:"alive"`
-	Ping  int64 `json:"ping"` // ms
+	Alive  bool   `json:"alive"`
+	Ping   int64  `json:"ping"`             // ms
+	Height uint64 `json:"height,omitempty"` // block or slot seen by the last probe, 0 = unknown
+	Lag    uint64 `json:"lag,omitempty"`    // blocks behind the network head
+	Reason string `json:"reason,omitempty"` // why the node is out of rotation
 }
 
 type DiscoveredNode struct {
@@ -30,4 +39,24 @@ type NetworkState struct {
 	Best       []NodeWithPing
 	Discovered []DiscoveredNode
 	TimeoutMs  int
+	// MaxBatchSize caps JSON-RPC calls sent to one upstream in a single batch
+	MaxBatchSize int
+	Cache        networks.CacheConfig
+	Hedge        networks.HedgeConfig
+	Broadcast    networks.BroadcastConfig
+	Strategy     string
+	Routing      []networks.MethodRoute
+	Methods      networks.MethodPolicy
+	Quorum       networks.QuorumConfig
+	MaxLagBlocks int
+	// ChainIdentity is what every upstream must report (see NetworkConfig.ChainIdentity)
+	ChainIdentity string
+	// Head is the highest block reported by the last full health round
+	Head uint64
+	// Status is the last health result per node URL, healthy or not; replaced, never mutated
+	Status map[string]NodeWithPing
+	// Disabled networks are not proxied
+	Disabled bool
+	// Config is what the network was registered with, the base for admin updates
+	Config networks.NetworkConfig
 }
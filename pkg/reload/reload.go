@@ -0,0 +1,328 @@
+// Package reload applies changes to configs/networks to a running registry.
+package reload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/health"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/metrics"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/store"
+)
+
+// Diff reports what a reload changed in the registry.
+type Diff struct {
+	Added     []string `json:"added"`
+	Updated   []string `json:"updated"`
+	Removed   []string `json:"removed"`
+	Unchanged int      `json:"unchanged"`
+}
+
+// Changed reports whether the reload touched any network.
+func (d Diff) Changed() bool {
+	return len(d.Added)+len(d.Updated)+len(d.Removed) > 0
+}
+
+// Reloader loads the network configs from Dir and keeps the registry in sync with them.
+// Only networks whose YAML changed since the last load are touched, so networks added or
+// edited through the admin API survive reloads.
+type Reloader struct {
+	Dir        string
+	Reg        *registry.Registry
+	Checker    *health.Checker
+	Store      *store.Store // nil when persistence is off
+	Precedence string
+	Logger     *zap.Logger
+	// OnChange, if set, is called for every network updated or removed by a reload
+	OnChange func(network string)
+
+	mu     sync.Mutex
+	yaml   map[string]networks.NetworkConfig // last loaded YAML, before merging admin state
+	loaded map[string]time.Time              // when each network's YAML was last applied
+	sum    string                            // fingerprint of the last loaded directory
+	bad    string                            // fingerprint of the last rejected directory
+}
+
+func New(dir string, reg *registry.Registry, st *store.Store, precedence string, logger *zap.Logger) *Reloader {
+	return &Reloader{Dir: dir, Reg: reg, Store: st, Precedence: precedence, Logger: logger, loaded: map[string]time.Time{}}
+}
+
+// Load reads the configs for the first time and returns the networks to register,
+// with the persisted admin state applied.
+func (r *Reloader) Load() (map[string]networks.NetworkConfig, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sum, err := Fingerprint(r.Dir)
+	if err != nil {
+		return nil, err
+	}
+	cfgs, err := networks.LoadAll(r.Dir, r.Logger)
+	if err != nil {
+		return nil, err
+	}
+	r.yaml, r.sum = cfgs, sum
+	r.loaded = make(map[string]time.Time, len(cfgs))
+	now := time.Now()
+	for name := range cfgs {
+		r.loaded[name] = now
+	}
+	return r.merge(cfgs, nil), nil
+}
+
+// Reload re-reads the configs and applies the networks whose YAML changed. An invalid
+// directory is rejected as a whole and the last good config stays active.
+func (r *Reloader) Reload() (Diff, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sum, err := Fingerprint(r.Dir)
+	if err != nil {
+		r.Logger.Error("networks_reload_error", zap.String("dir", r.Dir), zap.Error(err))
+		return Diff{}, err
+	}
+	cfgs, err := networks.LoadAll(r.Dir, r.Logger)
+	if err != nil {
+		r.bad = sum
+		metrics.ConfigReloads.WithLabelValues("rejected").Inc()
+		r.Logger.Error("networks_reload_rejected", zap.String("dir", r.Dir), zap.Error(err))
+		return Diff{}, err
+	}
+
+	var changed []string
+	for name := range union(r.yaml, cfgs) {
+		if !sameConfig(r.yaml[name], cfgs[name]) {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	var desired map[string]networks.NetworkConfig
+	if len(changed) > 0 {
+		desired = r.merge(cfgs, changed)
+	}
+
+	d := Diff{Added: []string{}, Updated: []string{}, Removed: []string{}}
+	d.Unchanged = len(union(r.yaml, cfgs)) - len(changed)
+	for _, name := range changed {
+		nc, want := desired[name]
+		if yml, inYAML := cfgs[name]; want != inYAML || (want && !sameConfig(nc, yml)) {
+			r.Logger.Warn("networks_reload_shadowed",
+				zap.String("network", name),
+				zap.String("hint", "admin state wins over the YAML change; DELETE /admin/networks/"+name+"/state drops it"),
+			)
+		}
+		live, exists := r.Reg.Config(name)
+		switch {
+		case !want && exists:
+			r.Reg.RemoveNetwork(name)
+			r.notify(name)
+			d.Removed = append(d.Removed, name)
+		case !want:
+			d.Unchanged++
+		case !exists:
+			r.Reg.InitFromConfigs(map[string]networks.NetworkConfig{name: nc})
+			r.check(name, nc)
+			d.Added = append(d.Added, name)
+		case sameConfig(live, nc):
+			d.Unchanged++
+		default:
+			r.Reg.ApplyConfig(name, nc)
+			r.notify(name)
+			d.Updated = append(d.Updated, name)
+		}
+	}
+	now := time.Now()
+	for _, name := range changed {
+		r.loaded[name] = now
+	}
+	r.yaml, r.sum, r.bad = cfgs, sum, ""
+	metrics.ConfigReloads.WithLabelValues("applied").Inc()
+	r.Logger.Info("networks_reloaded",
+		zap.Strings("added", d.Added),
+		zap.Strings("updated", d.Updated),
+		zap.Strings("removed", d.Removed),
+		zap.Int("unchanged", d.Unchanged),
+	)
+	return d, nil
+}
+
+// Known reports whether a network is in the last loaded YAML or in the registry.
+func (r *Reloader) Known(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, inYAML := r.yaml[name]
+	return inYAML || r.Reg.Has(name)
+}
+
+// Restore drops the persisted admin state of a network, recording the drop at version
+// v, and puts it back as the last loaded YAML defines it: updated, re-added, or removed
+// when it isn't in YAML. It reports false when the network is neither in YAML nor in
+// the registry.
+func (r *Reloader) Restore(name string, v store.Version) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	nc, inYAML := r.yaml[name]
+	live, exists := r.Reg.Config(name)
+	if !inYAML && !exists {
+		return false, nil
+	}
+	if err := r.Store.Forget(name, v); err != nil {
+		return true, err
+	}
+	r.loaded[name] = time.Now()
+	switch {
+	case !inYAML:
+		r.Reg.RemoveNetwork(name)
+		r.notify(name)
+	case !exists:
+		r.Reg.InitFromConfigs(map[string]networks.NetworkConfig{name: nc})
+		r.check(name, nc)
+	case !sameConfig(live, nc):
+		r.Reg.ApplyConfig(name, nc)
+		r.notify(name)
+	}
+	r.Logger.Info("networks_state_restored", zap.String("network", name), zap.Bool("in_yaml", inYAML))
+	return true, nil
+}
+
+// Overrides reports whether the YAML of a network wins over an admin change made at
+// at, e.g. one replicated from a peer. Under PrecedenceYAML, admin changes made before
+// the network's YAML was last applied were dropped then and must not come back.
+func (r *Reloader) Overrides(name string, at time.Time) bool {
+	if r.Precedence != store.PrecedenceYAML {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, inYAML := r.yaml[name]
+	return inYAML && at.Before(r.loaded[name])
+}
+
+// Watch polls Dir every interval and reloads when its contents change. Mounted
+// ConfigMaps are updated by swapping a symlink, which polling picks up as well.
+func (r *Reloader) Watch(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for range t.C {
+		sum, err := Fingerprint(r.Dir)
+		if err != nil {
+			r.Logger.Warn("networks_watch_error", zap.String("dir", r.Dir), zap.Error(err))
+			continue
+		}
+		r.mu.Lock()
+		seen := sum == r.sum || sum == r.bad
+		r.mu.Unlock()
+		if !seen {
+			r.Reload()
+		}
+	}
+}
+
+// merge applies the persisted admin state to cfgs. When only is non-nil, the state of
+// other networks is left alone, so it is neither logged nor forgotten again.
+func (r *Reloader) merge(cfgs map[string]networks.NetworkConfig, only []string) map[string]networks.NetworkConfig {
+	if r.Store == nil {
+		return cfgs
+	}
+	saved := r.Store.State()
+	if only != nil {
+		keep := make(map[string]bool, len(only))
+		for _, name := range only {
+			keep[name] = true
+		}
+		for name := range saved.Networks {
+			if !keep[name] {
+				delete(saved.Networks, name)
+			}
+		}
+		for name := range saved.Deleted {
+			if !keep[name] {
+				delete(saved.Deleted, name)
+			}
+		}
+	}
+	out, forget := store.Merge(cfgs, saved, r.Precedence, r.Logger)
+	for _, name := range forget {
+		if err := r.Store.Forget(name, store.Version{}); err != nil {
+			r.Logger.Error("state_write_error", zap.String("network", name), zap.Error(err))
+		}
+	}
+	return out
+}
+
+// check probes a newly added network so it serves traffic before the next health round.
+func (r *Reloader) check(name string, nc networks.NetworkConfig) {
+	if r.Checker == nil {
+		return
+	}
+	best := r.Checker.UpdateNetwork(name, nc.Protocol, nc.Nodes)
+	r.Reg.SetBest(name, best)
+	metrics.TotalNodes.WithLabelValues(name).Set(float64(len(nc.Nodes)))
+	metrics.HealthyNodes.WithLabelValues(name).Set(float64(len(best)))
+}
+
+func (r *Reloader) notify(name string) {
+	if r.OnChange != nil {
+		r.OnChange(name)
+	}
+}
+
+// Fingerprint hashes the names and contents of the YAML files in dir.
+func Fingerprint(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, e := range entries { // ReadDir sorts by name
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".yaml") {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte(e.Name()))
+		h.Write([]byte{0})
+		h.Write(b)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func union(a, b map[string]networks.NetworkConfig) map[string]struct{} {
+	out := make(map[string]struct{}, len(a)+len(b))
+	for name := range a {
+		out[name] = struct{}{}
+	}
+	for name := range b {
+		out[name] = struct{}{}
+	}
+	return out
+}
+
+// sameConfig compares two configs, treating nil and empty node headers alike.
+func sameConfig(a, b networks.NetworkConfig) bool {
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+func normalize(nc networks.NetworkConfig) networks.NetworkConfig {
+	nodes := make([]networks.Node, len(nc.Nodes))
+	for i, n := range nc.Nodes {
+		if len(n.Headers) == 0 {
+			n.Headers = nil
+		}
+		nodes[i] = n
+	}
+	nc.Nodes = nodes
+	return nc
+}
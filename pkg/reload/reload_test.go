@@ -0,0 +1,205 @@
+package reload
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/registry"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/store"
+)
+
+func writeNetwork(t *testing.T, dir, name, yml string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+".yaml"), []byte(yml), 0644))
+}
+
+func TestReload_AppliesDiff(t *testing.T) {
+	dir := t.TempDir()
+	writeNetwork(t, dir, "eth", `
+route: /eth
+protocol: evm
+nodes:
+  - url: https://a.example
+  - url: https://b.example
+`)
+	writeNetwork(t, dir, "bsc", `
+route: /bsc
+protocol: evm
+nodes:
+  - url: https://bsc.example
+`)
+	writeNetwork(t, dir, "matic", `
+route: /matic
+protocol: evm
+nodes:
+  - url: https://matic.example
+`)
+
+	reg := registry.New()
+	r := New(dir, reg, nil, "", zap.NewNop())
+	cfgs, err := r.Load()
+	require.NoError(t, err)
+	reg.InitFromConfigs(cfgs)
+	eth := reg.All()["eth"]
+	reg.SetBest("eth", []registry.NodeWithPing{{Node: eth.All[0], Ping: 10}, {Node: eth.All[1], Ping: 20}})
+	reg.AddNetwork(networks.NetworkConfig{Route: "/sol", Protocol: "solana", Nodes: []networks.Node{{URL: "https://sol.example"}}}, nil)
+
+	var forgotten []string
+	r.OnChange = func(network string) { forgotten = append(forgotten, network) }
+
+	// b.example gets a header, bsc is deleted, avax is new, matic is untouched
+	writeNetwork(t, dir, "eth", `
+route: /eth
+protocol: evm
+timeoutMs: 900
+nodes:
+  - url: https://a.example
+  - url: https://b.example
+    headers:
+      x-api-key: k
+`)
+	require.NoError(t, os.Remove(filepath.Join(dir, "bsc.yaml")))
+	writeNetwork(t, dir, "avax", `
+route: /avax
+protocol: evm
+nodes:
+  - url: https://avax.example
+`)
+
+	d, err := r.Reload()
+	require.NoError(t, err)
+	require.Equal(t, []string{"avax"}, d.Added)
+	require.Equal(t, []string{"eth"}, d.Updated)
+	require.Equal(t, []string{"bsc"}, d.Removed)
+	require.Equal(t, 1, d.Unchanged)
+	require.ElementsMatch(t, []string{"eth", "bsc"}, forgotten)
+
+	require.Equal(t, 900, reg.TimeoutMs("eth"))
+	best := reg.Best("eth")
+	require.Len(t, best, 1, "health of the unchanged node is kept")
+	require.Equal(t, "https://a.example", best[0].URL)
+	require.False(t, reg.Has("bsc"))
+	require.True(t, reg.Has("avax"))
+	require.True(t, reg.Has("sol"), "admin-added networks survive reloads")
+
+	// nothing changed on disk
+	d, err = r.Reload()
+	require.NoError(t, err)
+	require.False(t, d.Changed())
+}
+
+func TestReload_RejectsInvalidConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeNetwork(t, dir, "eth", `
+route: /eth
+protocol: evm
+nodes:
+  - url: https://a.example
+`)
+	reg := registry.New()
+	r := New(dir, reg, nil, "", zap.NewNop())
+	cfgs, err := r.Load()
+	require.NoError(t, err)
+	reg.InitFromConfigs(cfgs)
+
+	writeNetwork(t, dir, "eth", `
+route: /eth
+protocol: evm
+nodes: []
+`)
+	writeNetwork(t, dir, "avax", `
+route: /avax
+protocol: evm
+nodes:
+  - url: https://avax.example
+`)
+	_, err = r.Reload()
+	require.Error(t, err)
+	require.False(t, reg.Has("avax"), "nothing from a rejected config is applied")
+	eth, ok := reg.Config("eth")
+	require.True(t, ok)
+	require.Len(t, eth.Nodes, 1)
+}
+
+func TestReload_RestoresYAMLShadowedByAdminState(t *testing.T) {
+	dir := t.TempDir()
+	writeNetwork(t, dir, "eth", `
+route: /eth
+protocol: evm
+timeoutMs: 500
+nodes:
+  - url: https://a.example
+`)
+	b, err := store.NewFile(t.TempDir())
+	require.NoError(t, err)
+	st, _, err := store.Open(b)
+	require.NoError(t, err)
+
+	reg := registry.New()
+	r := New(dir, reg, st, store.PrecedenceAdmin, zap.NewNop())
+	cfgs, err := r.Load()
+	require.NoError(t, err)
+	reg.InitFromConfigs(cfgs)
+
+	// an admin change, and a network that only exists through the admin API
+	eth, _ := reg.Config("eth")
+	eth.TimeoutMs = 900
+	reg.ApplyConfig("eth", eth)
+	require.NoError(t, st.Put("eth", eth, store.Version{}))
+	sol := networks.NetworkConfig{Route: "sol", Protocol: "solana", Nodes: []networks.Node{{URL: "https://sol.example"}}}
+	reg.AddNetwork(sol, nil)
+	require.NoError(t, st.Put("sol", sol, store.Version{}))
+
+	writeNetwork(t, dir, "eth", `
+route: /eth
+protocol: evm
+timeoutMs: 700
+nodes:
+  - url: https://a.example
+`)
+	_, err = r.Reload()
+	require.NoError(t, err)
+	require.Equal(t, 900, reg.TimeoutMs("eth"), "admin state shadows the YAML edit")
+
+	found, err := r.Restore("eth", store.Version{})
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, 700, reg.TimeoutMs("eth"))
+	require.NotContains(t, st.State().Networks, "eth")
+
+	found, err = r.Restore("sol", store.Version{})
+	require.NoError(t, err)
+	require.True(t, found)
+	require.False(t, reg.Has("sol"), "a network not in YAML is removed")
+
+	found, err = r.Restore("bsc", store.Version{})
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestReload_OverridesOlderAdminChanges(t *testing.T) {
+	dir := t.TempDir()
+	writeNetwork(t, dir, "eth", `
+route: /eth
+protocol: evm
+nodes:
+  - url: https://a.example
+`)
+	before := time.Now().Add(-time.Minute)
+	r := New(dir, registry.New(), nil, store.PrecedenceYAML, zap.NewNop())
+	_, err := r.Load()
+	require.NoError(t, err)
+
+	require.True(t, r.Overrides("eth", before))
+	require.False(t, r.Overrides("eth", time.Now().Add(time.Minute)))
+	require.False(t, r.Overrides("sol", before), "networks not in YAML are admin-only")
+
+	r.Precedence = store.PrecedenceAdmin
+	require.False(t, r.Overrides("eth", before))
+}
@@ -0,0 +1,106 @@
+package replica
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+)
+
+// keys signs ops and seals their configs, both derived from the shared secret.
+type keys struct {
+	signKey []byte
+	sealKey []byte
+}
+
+// CheckSecret rejects a shared secret that can't protect replication: signed ops
+// change networks without the admin key, so a guessable secret gives them to anyone.
+func CheckSecret(secret string) error {
+	switch secret {
+	case "":
+		return errors.New("shared secret is empty")
+	case "devsecret":
+		return errors.New("shared secret is the default devsecret")
+	}
+	return nil
+}
+
+func deriveKeys(secret string) keys {
+	derive := func(label string) []byte {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(label))
+		return mac.Sum(nil)
+	}
+	return keys{signKey: derive("replica-sign"), sealKey: derive("replica-seal")}
+}
+
+func (k keys) sign(op Op) string {
+	mac := hmac.New(sha256.New, k.signKey)
+	fmt.Fprintf(mac, "%s\n%d\n%s\n%t\n%t\n%s", op.Network, op.Clock, op.Origin, op.Deleted, op.Restored, op.Config)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (k keys) seal(cfg networks.NetworkConfig) (string, error) {
+	plain, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := k.gcm()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, plain, nil)), nil
+}
+
+// open verifies an op and returns its config, nil for a deletion or a restore.
+func (k keys) open(op Op) (*networks.NetworkConfig, error) {
+	if !hmac.Equal([]byte(k.sign(op)), []byte(op.Sig)) {
+		return nil, errors.New("invalid signature")
+	}
+	if op.Deleted || op.Restored {
+		return nil, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(op.Config)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := k.gcm()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("sealed config too short")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, err
+	}
+	var cfg networks.NetworkConfig
+	if err := json.Unmarshal(plain, &cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (k keys) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k.sealKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,306 @@
+// Package replica replicates admin changes to every peer. A change is the full config
+// of one network, its deletion, or the drop of its admin state, versioned with a Lamport clock and signed with the
+// shared secret. Every replica keeps the newest version of each network, so the cluster
+// converges on the same networks whatever order the changes arrive in.
+package replica
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/metrics"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/peers"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/store"
+)
+
+// Op is one replicated change of a network.
+type Op struct {
+	Network string `json:"network"`
+	Clock   uint64 `json:"clock"`
+	Origin  string `json:"origin"` // replica that made the change, breaks clock ties
+	Deleted bool   `json:"deleted,omitempty"`
+	// Restored drops the admin state of the network, which goes back to its YAML version
+	Restored bool   `json:"restored,omitempty"`
+	Config   string `json:"config,omitempty"` // sealed networks.NetworkConfig, it may carry API keys
+	Sig      string `json:"sig"`
+}
+
+// newer reports whether o supersedes other.
+func (o Op) newer(other Op) bool {
+	if o.Clock != other.Clock {
+		return o.Clock > other.Clock
+	}
+	return o.Origin > other.Origin
+}
+
+// Message carries ops between replicas, in both directions of an exchange.
+type Message struct {
+	From string `json:"from"`
+	Ops  []Op   `json:"ops"`
+}
+
+type Replicator struct {
+	self   string
+	peers  *peers.Store
+	keys   keys
+	client *http.Client
+	logger *zap.Logger
+	// Apply installs a change received from a peer, made at version v; cfg is nil for a
+	// deletion
+	Apply func(network string, cfg *networks.NetworkConfig, v store.Version)
+	// Restore drops the admin state of a network on a restore op received from a peer
+	Restore func(network string, v store.Version)
+	// Skip, if set, reports ops that must not be applied here, e.g. admin changes the
+	// local precedence rules dropped; they are still kept and passed on
+	Skip func(network string, at time.Time) bool
+	// Port, if set, replaces the port of peer addresses: /replica is served on its own
+	// listener, apart from the public one
+	Port string
+
+	mu    sync.Mutex
+	clock uint64
+	ops   map[string]Op // newest op seen per network
+	// applyMu keeps received ops applied in the order they won, without holding mu
+	// (and so Publish and exchanges) behind the store and health checks
+	applyMu sync.Mutex
+}
+
+func New(self, secret string, ps *peers.Store, logger *zap.Logger) *Replicator {
+	return &Replicator{
+		self:   self,
+		peers:  ps,
+		keys:   deriveKeys(secret),
+		client: &http.Client{Timeout: 5 * time.Second},
+		logger: logger,
+		ops:    map[string]Op{},
+	}
+}
+
+// Seed rebuilds the ops from the persisted state, so a restarted replica keeps the
+// versions of its networks and doesn't take older ones from stale peers. Ops are
+// re-signed with the local keys, which all replicas share.
+func (r *Replicator) Seed(st store.State) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, v := range st.Versions {
+		op := Op{Network: name, Clock: v.Clock, Origin: v.Origin}
+		if nc, ok := st.Networks[name]; ok {
+			sealed, err := r.keys.seal(nc)
+			if err != nil {
+				r.logger.Error("replica_seal_error", zap.String("network", name), zap.Error(err))
+				continue
+			}
+			op.Config = sealed
+		} else if _, ok := st.Deleted[name]; ok {
+			op.Deleted = true
+		} else {
+			op.Restored = true
+		}
+		op.Sig = r.keys.sign(op)
+		r.ops[name] = op
+		r.clock = max(r.clock, v.Clock)
+	}
+	r.logger.Info("replica_seeded", zap.Int("ops", len(r.ops)))
+}
+
+// Publish records a local change and pushes it to every peer; cfg is nil for a
+// deletion. It returns the version of the change, zero for a nil Replicator, which
+// does nothing.
+func (r *Replicator) Publish(network string, cfg *networks.NetworkConfig) store.Version {
+	if r == nil {
+		return store.Version{}
+	}
+	return r.publish(network, cfg, false)
+}
+
+// PublishRestore records that the admin state of a network was dropped and pushes it
+// to every peer, so they go back to the YAML version as well. A nil Replicator does
+// nothing.
+func (r *Replicator) PublishRestore(network string) store.Version {
+	if r == nil {
+		return store.Version{}
+	}
+	return r.publish(network, nil, true)
+}
+
+func (r *Replicator) publish(network string, cfg *networks.NetworkConfig, restored bool) store.Version {
+	r.mu.Lock()
+	// the wall-clock floor keeps a restarted replica from reusing old clock values
+	r.clock = max(r.clock+1, uint64(time.Now().UnixMilli()))
+	op := Op{Network: network, Clock: r.clock, Origin: r.self, Deleted: cfg == nil && !restored, Restored: restored}
+	if cfg != nil {
+		sealed, err := r.keys.seal(*cfg)
+		if err != nil {
+			r.mu.Unlock()
+			r.logger.Error("replica_seal_error", zap.String("network", network), zap.Error(err))
+			return store.Version{}
+		}
+		op.Config = sealed
+	}
+	op.Sig = r.keys.sign(op)
+	r.ops[network] = op
+	r.mu.Unlock()
+
+	metrics.ReplicaOps.WithLabelValues("published").Inc()
+	r.logger.Info("replica_op_published",
+		zap.String("network", network),
+		zap.Uint64("clock", op.Clock),
+		zap.Bool("deleted", op.Deleted),
+		zap.Bool("restored", op.Restored),
+	)
+	for _, p := range r.peers.List() {
+		if p.ID != r.self {
+			go r.exchange(p, []Op{op})
+		}
+	}
+	return store.Version{Clock: op.Clock, Origin: op.Origin}
+}
+
+// accepted is a received op that won over the local version, with its opened config.
+type accepted struct {
+	op  Op
+	cfg *networks.NetworkConfig
+}
+
+// Receive merges ops from a peer and applies those newer than the local version.
+// Ops with a bad signature are dropped.
+func (r *Replicator) Receive(from string, ops []Op) {
+	r.applyMu.Lock()
+	defer r.applyMu.Unlock()
+
+	var won []accepted
+	r.mu.Lock()
+	for _, op := range ops {
+		cfg, err := r.keys.open(op)
+		if err != nil {
+			metrics.ReplicaOps.WithLabelValues("rejected").Inc()
+			r.logger.Warn("replica_op_rejected", zap.String("from", from), zap.String("network", op.Network), zap.Error(err))
+			continue
+		}
+		r.clock = max(r.clock, op.Clock)
+		if cur, ok := r.ops[op.Network]; ok && !op.newer(cur) {
+			continue
+		}
+		r.ops[op.Network] = op
+		won = append(won, accepted{op: op, cfg: cfg})
+	}
+	r.mu.Unlock()
+
+	for _, a := range won {
+		op := a.op
+		if r.Skip != nil && r.Skip(op.Network, time.UnixMilli(int64(op.Clock))) {
+			metrics.ReplicaOps.WithLabelValues("skipped").Inc()
+			r.logger.Info("replica_op_skipped",
+				zap.String("from", from),
+				zap.String("origin", op.Origin),
+				zap.String("network", op.Network),
+				zap.Uint64("clock", op.Clock),
+			)
+			continue
+		}
+		v := store.Version{Clock: op.Clock, Origin: op.Origin}
+		switch {
+		case op.Restored:
+			if r.Restore != nil {
+				r.Restore(op.Network, v)
+			}
+		case r.Apply != nil:
+			r.Apply(op.Network, a.cfg, v)
+		}
+		metrics.ReplicaOps.WithLabelValues("applied").Inc()
+		r.logger.Info("replica_op_applied",
+			zap.String("from", from),
+			zap.String("origin", op.Origin),
+			zap.String("network", op.Network),
+			zap.Uint64("clock", op.Clock),
+			zap.Bool("deleted", op.Deleted),
+			zap.Bool("restored", op.Restored),
+		)
+	}
+}
+
+// Ops returns the newest op of every network.
+func (r *Replicator) Ops() []Op {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Op, 0, len(r.ops))
+	for _, op := range r.ops {
+		out = append(out, op)
+	}
+	return out
+}
+
+// Handler takes pushed ops and answers with the local ones, so each exchange syncs
+// both sides.
+func (r *Replicator) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var msg Message
+		if err := json.NewDecoder(req.Body).Decode(&msg); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.Receive(msg.From, msg.Ops)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Message{From: r.self, Ops: r.Ops()})
+	}
+}
+
+// Start exchanges all ops with every known peer once, to catch up after a restart,
+// then with a random peer every interval, which repairs pushes that were lost.
+func (r *Replicator) Start(interval time.Duration) {
+	for _, p := range r.peers.List() {
+		if p.ID != r.self {
+			r.exchange(p, r.Ops())
+		}
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for range t.C {
+		var others []peers.Peer
+		for _, p := range r.peers.List() {
+			if p.ID != r.self {
+				others = append(others, p)
+			}
+		}
+		if len(others) == 0 {
+			continue
+		}
+		r.exchange(others[rand.Intn(len(others))], r.Ops())
+	}
+}
+
+func (r *Replicator) exchange(p peers.Peer, ops []Op) {
+	data, _ := json.Marshal(Message{From: r.self, Ops: ops})
+	addr := p.Addr
+	if host, _, err := net.SplitHostPort(addr); err == nil && r.Port != "" {
+		addr = net.JoinHostPort(host, r.Port)
+	}
+	resp, err := r.client.Post("http://"+addr+"/replica", "application/json", bytes.NewReader(data))
+	if err != nil {
+		r.logger.Debug("replica_send_error", zap.String("peer", p.ID), zap.Error(err))
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		r.logger.Debug("replica_send_error", zap.String("peer", p.ID), zap.Int("status", resp.StatusCode))
+		return
+	}
+	var reply Message
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		r.logger.Debug("replica_reply_error", zap.String("peer", p.ID), zap.Error(err))
+		return
+	}
+	r.Receive(p.ID, reply.Ops)
+}
@@ -0,0 +1,232 @@
+package replica
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/peers"
+	"github.com/shuliakovsky/rpc-forwarder/pkg/store"
+)
+
+// node is a replica with the networks it has applied from its peers.
+type node struct {
+	*Replicator
+	mu   sync.Mutex
+	nets map[string]networks.NetworkConfig
+}
+
+func (n *node) get(name string) (networks.NetworkConfig, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	nc, ok := n.nets[name]
+	return nc, ok
+}
+
+// newCluster starts replicas that all know each other.
+func newCluster(t *testing.T, secrets ...string) []*node {
+	t.Helper()
+	ps := peers.NewStore()
+	nodes := make([]*node, len(secrets))
+	for i, secret := range secrets {
+		id := string(rune('a' + i))
+		n := &node{Replicator: New(id, secret, ps, zap.NewNop()), nets: map[string]networks.NetworkConfig{}}
+		n.Apply = func(network string, cfg *networks.NetworkConfig, _ store.Version) {
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			if cfg == nil {
+				delete(n.nets, network)
+				return
+			}
+			n.nets[network] = *cfg
+		}
+		srv := httptest.NewServer(n.Handler())
+		t.Cleanup(srv.Close)
+		ps.Add(peers.Peer{ID: id, Addr: strings.TrimPrefix(srv.URL, "http://")})
+		nodes[i] = n
+	}
+	return nodes
+}
+
+func evmNetwork(url string) *networks.NetworkConfig {
+	return &networks.NetworkConfig{
+		Route:    "eth",
+		Protocol: "evm",
+		Nodes:    []networks.Node{{URL: url, Priority: 1, Headers: map[string]string{"x-api-key": "k"}}},
+	}
+}
+
+func TestReplicator_PropagatesChanges(t *testing.T) {
+	c := newCluster(t, "s", "s", "s")
+
+	c[0].Publish("eth", evmNetwork("https://a.example"))
+	for _, n := range c[1:] {
+		require.Eventually(t, func() bool {
+			nc, ok := n.get("eth")
+			return ok && nc.Nodes[0].Headers["x-api-key"] == "k"
+		}, time.Second, 10*time.Millisecond)
+	}
+
+	c[1].Publish("eth", nil)
+	for _, n := range []*node{c[0], c[2]} {
+		require.Eventually(t, func() bool {
+			_, ok := n.get("eth")
+			return !ok
+		}, time.Second, 10*time.Millisecond)
+	}
+}
+
+func TestReplicator_PropagatesRestores(t *testing.T) {
+	c := newCluster(t, "s", "s")
+	var mu sync.Mutex
+	var restored []string
+	c[1].Restore = func(network string, _ store.Version) {
+		mu.Lock()
+		defer mu.Unlock()
+		restored = append(restored, network)
+	}
+
+	c[0].Publish("eth", evmNetwork("https://a.example"))
+	require.Eventually(t, func() bool {
+		_, ok := c[1].get("eth")
+		return ok
+	}, time.Second, 10*time.Millisecond)
+
+	c[0].PublishRestore("eth")
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(restored) == 1 && restored[0] == "eth"
+	}, time.Second, 10*time.Millisecond)
+
+	// the restore supersedes the admin version, so an exchange doesn't bring it back
+	c[1].Receive("a", c[0].Ops())
+	require.Len(t, c[1].Ops(), 1)
+	require.True(t, c[1].Ops()[0].Restored)
+}
+
+func TestReplicator_ConvergesOnNewestOp(t *testing.T) {
+	applied := map[string][]string{}
+	replica := func(id string) *Replicator {
+		r := New(id, "s", peers.NewStore(), zap.NewNop())
+		r.Apply = func(_ string, cfg *networks.NetworkConfig, _ store.Version) {
+			applied[id] = append(applied[id], cfg.Nodes[0].URL)
+		}
+		return r
+	}
+	a, b := replica("a"), replica("b")
+
+	// both replicas change eth concurrently, a with the higher clock
+	a.clock = 1 << 62
+	a.Publish("eth", evmNetwork("https://a.example"))
+	b.Publish("eth", evmNetwork("https://b.example"))
+	a.Receive("b", b.Ops())
+	b.Receive("a", a.Ops())
+
+	require.Empty(t, applied["a"], "an older op does not overwrite a newer one")
+	require.Equal(t, []string{"https://a.example"}, applied["b"])
+	require.Equal(t, a.Ops(), b.Ops())
+
+	// the clock moves past every op seen, so the next change wins
+	b.Publish("eth", evmNetwork("https://c.example"))
+	a.Receive("b", b.Ops())
+	require.Equal(t, []string{"https://c.example"}, applied["a"])
+}
+
+func TestReplicator_SkipsOpsDroppedLocally(t *testing.T) {
+	a := New("a", "s", peers.NewStore(), zap.NewNop())
+	a.Publish("eth", evmNetwork("https://old.example"))
+	restarted := time.Now().Add(time.Second)
+
+	b := New("b", "s", peers.NewStore(), zap.NewNop())
+	var applied []string
+	b.Apply = func(_ string, cfg *networks.NetworkConfig, _ store.Version) {
+		applied = append(applied, cfg.Nodes[0].URL)
+	}
+	b.Skip = func(_ string, at time.Time) bool { return at.Before(restarted) }
+
+	b.Receive("a", a.Ops())
+	require.Empty(t, applied, "a change made before the YAML won is not brought back")
+	require.Len(t, b.Ops(), 1, "skipped ops are still passed on")
+
+	a.clock = uint64(restarted.Add(time.Second).UnixMilli())
+	a.Publish("eth", evmNetwork("https://new.example"))
+	b.Receive("a", a.Ops())
+	require.Equal(t, []string{"https://new.example"}, applied)
+}
+
+func TestReplicator_SeedsFromPersistedState(t *testing.T) {
+	stale := New("b", "s", peers.NewStore(), zap.NewNop())
+	stale.Publish("eth", evmNetwork("https://old.example"))
+	stale.Publish("bsc", evmNetwork("https://bsc.example"))
+
+	// the restarted replica changed eth after the stale one, and restored bsc
+	v := stale.Ops()[0].Clock + 1
+	st := store.State{
+		Networks: map[string]networks.NetworkConfig{"eth": *evmNetwork("https://new.example")},
+		Deleted:  map[string]time.Time{},
+		Versions: map[string]store.Version{"eth": {Clock: v, Origin: "a"}, "bsc": {Clock: v + 1, Origin: "a"}},
+	}
+	a := New("a", "s", peers.NewStore(), zap.NewNop())
+	var applied []string
+	a.Apply = func(network string, _ *networks.NetworkConfig, _ store.Version) { applied = append(applied, network) }
+	a.Seed(st)
+	require.Len(t, a.Ops(), 2)
+
+	a.Receive("b", stale.Ops())
+	require.Empty(t, applied, "older versions from a stale peer lose to the persisted ones")
+
+	// seeded ops are signed, so peers take them
+	stale.Receive("a", a.Ops())
+	for _, op := range stale.Ops() {
+		require.Equal(t, "a", op.Origin)
+	}
+	require.Greater(t, a.Publish("eth", nil).Clock, v+1, "the clock moves past the persisted versions")
+}
+
+func TestReplicator_AppliesOutsideTheLock(t *testing.T) {
+	a := New("a", "s", peers.NewStore(), zap.NewNop())
+	a.Publish("eth", evmNetwork("https://a.example"))
+
+	b := New("b", "s", peers.NewStore(), zap.NewNop())
+	done := make(chan struct{})
+	b.Apply = func(string, *networks.NetworkConfig, store.Version) {
+		b.Publish("bsc", evmNetwork("https://bsc.example")) // would deadlock under the lock
+		close(done)
+	}
+	go b.Receive("a", a.Ops())
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Apply runs while Receive holds the lock")
+	}
+}
+
+func TestReplicator_RejectsForeignSignatures(t *testing.T) {
+	c := newCluster(t, "s", "other")
+
+	c[1].Publish("eth", evmNetwork("https://a.example"))
+	time.Sleep(100 * time.Millisecond)
+	_, ok := c[0].get("eth")
+	require.False(t, ok)
+
+	signed := New("c", "s", peers.NewStore(), zap.NewNop())
+	signed.Publish("eth", evmNetwork("https://a.example"))
+	op := signed.Ops()[0]
+	op.Config = ""
+	op.Deleted = true
+	c[0].Receive("c", []Op{op})
+	require.Empty(t, c[0].Ops(), "tampered ops are dropped")
+}
+
+func TestCheckSecret(t *testing.T) {
+	require.Error(t, CheckSecret(""))
+	require.Error(t, CheckSecret("devsecret"))
+	require.NoError(t, CheckSecret("3f9c1a7e5b"))
+}
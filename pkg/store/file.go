@@ -0,0 +1,175 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+)
+
+const (
+	snapshotFile = "snapshot.json"
+	changeLog    = "changes.log"
+	// compactEvery is how many appended changes trigger a new snapshot
+	compactEvery = 500
+)
+
+// File keeps the state in a directory: a JSON snapshot and an append-only log of the
+// changes made since, one JSON object per line. The log is folded into a new snapshot
+// on load and every compactEvery changes. Files are private to the owner: configs may
+// carry API keys.
+type File struct {
+	dir string
+
+	mu       sync.Mutex
+	state    State
+	appended int
+}
+
+// NewFile returns a file backend writing to dir, created if missing.
+func NewFile(dir string) (*File, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &File{dir: dir, state: newState()}, nil
+}
+
+// Load reads the snapshot and replays the log. A torn last line, left by a crash in
+// the middle of a write, is dropped.
+func (f *File) Load() (State, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	st := newState()
+	b, err := os.ReadFile(filepath.Join(f.dir, snapshotFile))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return State{}, err
+	default:
+		if err := json.Unmarshal(b, &st); err != nil {
+			return State{}, fmt.Errorf("%s: %w", snapshotFile, err)
+		}
+		if st.Networks == nil {
+			st.Networks = map[string]networks.NetworkConfig{}
+		}
+		if st.Deleted == nil {
+			st.Deleted = map[string]time.Time{}
+		}
+		if st.Versions == nil {
+			st.Versions = map[string]Version{}
+		}
+	}
+
+	b, err = os.ReadFile(filepath.Join(f.dir, changeLog))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return State{}, err
+	}
+	lines := bytes.Split(b, []byte("\n"))
+	for i, l := range lines {
+		if len(bytes.TrimSpace(l)) == 0 {
+			continue
+		}
+		var c Change
+		if err := json.Unmarshal(l, &c); err != nil {
+			if i == len(lines)-1 {
+				break // torn write: the last line has no newline
+			}
+			return State{}, fmt.Errorf("%s:%d: %w", changeLog, i+1, err)
+		}
+		st.Apply(c)
+	}
+
+	f.state = st
+	if err := f.compact(); err != nil {
+		return State{}, err
+	}
+	return cloneState(st), nil
+}
+
+// Append writes c to the log and syncs it before returning.
+func (f *File) Append(c Change) error {
+	line, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fh, err := os.OpenFile(filepath.Join(f.dir, changeLog), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := fh.Write(append(line, '\n')); err != nil {
+		fh.Close()
+		return err
+	}
+	if err := fh.Sync(); err != nil {
+		fh.Close()
+		return err
+	}
+	if err := fh.Close(); err != nil {
+		return err
+	}
+	f.state.Apply(c)
+	f.appended++
+	if f.appended >= compactEvery {
+		return f.compact()
+	}
+	return nil
+}
+
+// compact writes the state as a new snapshot, then empties the log. A crash between
+// the two is harmless: replayed changes at or below the snapshot's Seq are skipped.
+func (f *File) compact() error {
+	b, err := json.MarshalIndent(f.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(f.dir, snapshotFile+".tmp")
+	fh, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := fh.Write(b); err != nil {
+		fh.Close()
+		return err
+	}
+	if err := fh.Sync(); err != nil {
+		fh.Close()
+		return err
+	}
+	if err := fh.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(f.dir, snapshotFile)); err != nil {
+		return err
+	}
+	if err := os.Truncate(filepath.Join(f.dir, changeLog), 0); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	f.appended = 0
+	return nil
+}
+
+func cloneState(s State) State {
+	out := newState()
+	out.Seq = s.Seq
+	for k, v := range s.Networks {
+		out.Networks[k] = v
+	}
+	for k, v := range s.Deleted {
+		out.Deleted[k] = v
+	}
+	for k, v := range s.Versions {
+		out.Versions[k] = v
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+)
+
+// Precedence rules for networks defined in configs/networks that were also changed
+// through the admin API. Networks created through the admin API and absent from YAML
+// are restored under both.
+const (
+	// PrecedenceAdmin restores admin changes over YAML, deletions included; YAML edits
+	// to such networks are ignored until their admin state is dropped.
+	PrecedenceAdmin = "admin"
+	// PrecedenceYAML loads YAML networks as written and drops their admin state.
+	PrecedenceYAML = "yaml"
+)
+
+// ValidatePrecedence checks a precedence rule name.
+func ValidatePrecedence(p string) error {
+	switch p {
+	case PrecedenceAdmin, PrecedenceYAML:
+		return nil
+	}
+	return fmt.Errorf("unknown state precedence %q (want %s or %s)", p, PrecedenceAdmin, PrecedenceYAML)
+}
+
+// Merge applies the persisted state to the networks loaded from YAML and returns the
+// networks to register, plus those whose admin state lost to YAML and should be
+// forgotten. Persisted configs that no longer validate are skipped.
+func Merge(yaml map[string]networks.NetworkConfig, st State, precedence string, logger *zap.Logger) (map[string]networks.NetworkConfig, []string) {
+	out := make(map[string]networks.NetworkConfig, len(yaml)+len(st.Networks))
+	for name, nc := range yaml {
+		out[name] = nc
+	}
+	var forget []string
+	for name, nc := range st.Networks {
+		_, inYAML := yaml[name]
+		if inYAML && precedence == PrecedenceYAML {
+			logger.Info("state_yaml_wins", zap.String("network", name))
+			forget = append(forget, name)
+			continue
+		}
+		if err := nc.Validate(); err != nil {
+			logger.Error("state_network_invalid", zap.String("network", name), zap.Error(err))
+			continue
+		}
+		if inYAML {
+			logger.Warn("state_overrides_yaml", zap.String("network", name))
+		} else {
+			logger.Info("state_network_restored", zap.String("network", name))
+		}
+		out[name] = nc
+	}
+	for name := range st.Deleted {
+		if _, inYAML := yaml[name]; !inYAML {
+			continue
+		}
+		if precedence == PrecedenceYAML {
+			logger.Info("state_yaml_wins", zap.String("network", name))
+			forget = append(forget, name)
+			continue
+		}
+		logger.Warn("state_network_deleted", zap.String("network", name))
+		delete(out, name)
+	}
+	return out, forget
+}
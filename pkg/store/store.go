@@ -0,0 +1,153 @@
+// Package store persists runtime changes to the registry (networks added, updated or
+// removed through the admin API) so they survive restarts. Changes are recorded per
+// network: the full config after the change, or its removal.
+package store
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+)
+
+// Change operations.
+const (
+	OpPut    = "put"    // the network's config after the change
+	OpDelete = "delete" // the network was removed
+	OpForget = "forget" // the admin state of the network was dropped, its YAML applies again
+)
+
+// Version orders a change across replicas, see pkg/replica: the Lamport clock of the
+// change and the replica that made it. The zero Version marks a change made while
+// replication is off.
+type Version struct {
+	Clock  uint64 `json:"clock"`
+	Origin string `json:"origin"`
+}
+
+// Change is one recorded registry mutation.
+type Change struct {
+	Seq     uint64                  `json:"seq"`
+	At      time.Time               `json:"at"`
+	Op      string                  `json:"op"`
+	Network string                  `json:"network"`
+	Config  *networks.NetworkConfig `json:"config,omitempty"`
+	Version *Version                `json:"version,omitempty"`
+}
+
+// State is the persisted admin state: networks put through the admin API, the names
+// of networks it removed, and the replication version of each network's last change.
+type State struct {
+	Seq      uint64                            `json:"seq"` // last change applied
+	Networks map[string]networks.NetworkConfig `json:"networks"`
+	Deleted  map[string]time.Time              `json:"deleted"`
+	Versions map[string]Version                `json:"versions,omitempty"`
+}
+
+func newState() State {
+	return State{Networks: map[string]networks.NetworkConfig{}, Deleted: map[string]time.Time{}, Versions: map[string]Version{}}
+}
+
+// Apply folds a change into the state; changes at or below Seq are ignored.
+func (s *State) Apply(c Change) {
+	if c.Seq <= s.Seq {
+		return
+	}
+	s.Seq = c.Seq
+	switch c.Op {
+	case OpPut:
+		if c.Config != nil {
+			s.Networks[c.Network] = *c.Config
+			delete(s.Deleted, c.Network)
+		}
+	case OpDelete:
+		delete(s.Networks, c.Network)
+		s.Deleted[c.Network] = c.At
+	case OpForget:
+		delete(s.Networks, c.Network)
+		delete(s.Deleted, c.Network)
+	default:
+		return
+	}
+	if c.Version != nil {
+		s.Versions[c.Network] = *c.Version
+	} else {
+		delete(s.Versions, c.Network)
+	}
+}
+
+// Backend stores changes durably. Load is called once, before any Append.
+type Backend interface {
+	Load() (State, error)
+	Append(c Change) error
+}
+
+// Store numbers changes and hands them to a backend. A nil *Store records nothing.
+type Store struct {
+	mu      sync.Mutex
+	backend Backend
+	state   State
+}
+
+// Open loads the persisted state from b.
+func Open(b Backend) (*Store, State, error) {
+	st, err := b.Load()
+	if err != nil {
+		return nil, State{}, err
+	}
+	if st.Networks == nil {
+		st.Networks = map[string]networks.NetworkConfig{}
+	}
+	if st.Deleted == nil {
+		st.Deleted = map[string]time.Time{}
+	}
+	if st.Versions == nil {
+		st.Versions = map[string]Version{}
+	}
+	return &Store{backend: b, state: cloneState(st)}, st, nil
+}
+
+// State returns the persisted state, including the changes recorded since Open.
+func (s *Store) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return cloneState(s.state)
+}
+
+// Put records the config of a network after an admin change, made at version v.
+func (s *Store) Put(network string, cfg networks.NetworkConfig, v Version) error {
+	return s.append(Change{Op: OpPut, Network: network, Config: &cfg, Version: versionOf(v)})
+}
+
+// Delete records the removal of a network, made at version v.
+func (s *Store) Delete(network string, v Version) error {
+	return s.append(Change{Op: OpDelete, Network: network, Version: versionOf(v)})
+}
+
+// Forget drops the recorded state of a network. A non-zero v is kept as the version
+// of the drop, so replicas don't bring back older admin changes.
+func (s *Store) Forget(network string, v Version) error {
+	return s.append(Change{Op: OpForget, Network: network, Version: versionOf(v)})
+}
+
+func versionOf(v Version) *Version {
+	if v == (Version{}) {
+		return nil
+	}
+	return &v
+}
+
+func (s *Store) append(c Change) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c.Seq = s.state.Seq + 1
+	c.At = time.Now().UTC()
+	if err := s.backend.Append(c); err != nil {
+		return err
+	}
+	s.state.Apply(c)
+	return nil
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/shuliakovsky/rpc-forwarder/pkg/networks"
+)
+
+func evmNetwork(route string, timeoutMs int) networks.NetworkConfig {
+	return networks.NetworkConfig{
+		Route:     route,
+		Protocol:  "evm",
+		TimeoutMs: timeoutMs,
+		Nodes:     []networks.Node{{URL: "https://rpc.example", Priority: 1}},
+	}
+}
+
+func TestFile_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	b, err := NewFile(dir)
+	require.NoError(t, err)
+	s, st, err := Open(b)
+	require.NoError(t, err)
+	require.Empty(t, st.Networks)
+
+	require.NoError(t, s.Put("eth", evmNetwork("/eth", 500), Version{}))
+	require.NoError(t, s.Put("matic", evmNetwork("matic", 0), Version{}))
+	require.NoError(t, s.Put("eth", evmNetwork("/eth", 900), Version{}))
+	require.NoError(t, s.Delete("matic", Version{}))
+	require.NoError(t, s.Delete("bsc", Version{}))
+	require.NoError(t, s.Forget("bsc", Version{}))
+
+	// a crash in the middle of a write leaves a torn last line
+	f, err := os.OpenFile(filepath.Join(dir, changeLog), os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"seq":7,"op":"put","netw`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	b, err = NewFile(dir)
+	require.NoError(t, err)
+	s, st, err = Open(b)
+	require.NoError(t, err)
+	require.EqualValues(t, 6, st.Seq)
+	require.Len(t, st.Networks, 1)
+	require.Equal(t, 900, st.Networks["eth"].TimeoutMs)
+	require.Contains(t, st.Deleted, "matic")
+	require.NotContains(t, st.Deleted, "bsc")
+
+	// loading compacted the log into the snapshot; numbering continues
+	log, err := os.ReadFile(filepath.Join(dir, changeLog))
+	require.NoError(t, err)
+	require.Empty(t, log)
+	require.NoError(t, s.Delete("eth", Version{}))
+	b, err = NewFile(dir)
+	require.NoError(t, err)
+	_, st, err = Open(b)
+	require.NoError(t, err)
+	require.EqualValues(t, 7, st.Seq)
+	require.Empty(t, st.Networks)
+
+	info, err := os.Stat(filepath.Join(dir, snapshotFile))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestFile_KeepsVersions(t *testing.T) {
+	dir := t.TempDir()
+	b, err := NewFile(dir)
+	require.NoError(t, err)
+	s, _, err := Open(b)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Put("eth", evmNetwork("/eth", 500), Version{Clock: 10, Origin: "a"}))
+	require.NoError(t, s.Delete("bsc", Version{Clock: 11, Origin: "b"}))
+	require.NoError(t, s.Forget("arb", Version{Clock: 12, Origin: "a"}))
+	require.NoError(t, s.Put("matic", evmNetwork("/matic", 500), Version{Clock: 13, Origin: "a"}))
+	require.NoError(t, s.Put("matic", evmNetwork("/matic", 900), Version{}))
+
+	b, err = NewFile(dir)
+	require.NoError(t, err)
+	_, st, err := Open(b)
+	require.NoError(t, err)
+	require.Equal(t, map[string]Version{
+		"eth": {Clock: 10, Origin: "a"},
+		"bsc": {Clock: 11, Origin: "b"},
+		"arb": {Clock: 12, Origin: "a"},
+	}, st.Versions, "a change made without replication drops the version")
+}
+
+func TestMerge(t *testing.T) {
+	yaml := map[string]networks.NetworkConfig{
+		"eth": evmNetwork("/eth", 1500),
+		"bsc": evmNetwork("/bsc", 1500),
+		"arb": evmNetwork("/arb", 1500),
+	}
+	st := newState()
+	st.Networks["eth"] = evmNetwork("/eth", 500)    // changed through admin
+	st.Networks["matic"] = evmNetwork("matic", 700) // created through admin
+	bad := evmNetwork("bad", 0)
+	bad.Strategy = "fastest"
+	st.Networks["bad"] = bad
+	st.Deleted["bsc"] = time.Now() // removed through admin
+
+	out, forget := Merge(yaml, st, PrecedenceAdmin, zap.NewNop())
+	require.Empty(t, forget)
+	require.Len(t, out, 3)
+	require.Equal(t, 500, out["eth"].TimeoutMs)
+	require.Equal(t, 700, out["matic"].TimeoutMs)
+	require.Equal(t, 1500, out["arb"].TimeoutMs)
+	require.NotContains(t, out, "bsc")
+	require.NotContains(t, out, "bad")
+
+	out, forget = Merge(yaml, st, PrecedenceYAML, zap.NewNop())
+	require.ElementsMatch(t, []string{"eth", "bsc"}, forget)
+	require.Len(t, out, 4)
+	require.Equal(t, 1500, out["eth"].TimeoutMs)
+	require.Equal(t, 700, out["matic"].TimeoutMs)
+	require.Contains(t, out, "bsc")
+
+	require.NoError(t, ValidatePrecedence(PrecedenceYAML))
+	require.Error(t, ValidatePrecedence("newest"))
+}